@@ -0,0 +1,201 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/potom-dev/backend/internal/auth"
+	"github.com/potom-dev/backend/internal/database"
+	"github.com/potom-dev/backend/internal/denylist"
+)
+
+// accountDeletionGracePeriod is how long a deleted account is kept, so an
+// admin can still restore it, before it is purged for good.
+const accountDeletionGracePeriod = 30 * 24 * time.Hour
+
+type DeleteAccountParams struct {
+	Password     string `json:"password,omitempty"`
+	Code         string `json:"code,omitempty"`
+	RecoveryCode string `json:"recovery_code,omitempty"`
+}
+
+type AccountDeletionResponse struct {
+	PurgeAt time.Time `json:"purge_at"`
+}
+
+// handlerDeleteMe godoc
+//
+//	@Router		/users/me [delete]
+//	@Summary	delete the caller's account
+//	@Description	Requires the account password, if it has one, and a TOTP or recovery code if two-factor authentication is enabled. The account is signed out everywhere at once and purged after a grace period. Groups the user owns pass to their most senior other member, or are deleted if they have no other members.
+//	@Tags		users
+//	@Accept		json
+//	@Produce	json
+//	@Param		Authorization	header	string	true	"Bearer token"
+//	@Param		body	body		DeleteAccountParams	true	"Confirmation"
+//	@Success	202		{object}	AccountDeletionResponse
+//	@Failure	400		{object}	Problem
+//	@Failure	401		{object}	Problem
+//	@Failure	422		{object}	Problem
+//	@Failure	429		{object}	Problem
+//	@Failure	500		{object}	Problem
+//	@Security	BearerAuth
+func (cfg *Config) handlerDeleteMe(w http.ResponseWriter, r *http.Request) error {
+	params := DeleteAccountParams{}
+	if err := decodeParams(w, r, &params); err != nil {
+		return err
+	}
+
+	user, err := cfg.db.GetUserById(r.Context(), UserIDFromContext(r.Context()))
+	if err != nil {
+		return newError(codeInternal, "Couldn't get user", err)
+	}
+
+	// Wrong passwords and codes count as failed logins, as they do for
+	// disabling TOTP.
+	attempt, err := cfg.startLoginAttempt(w, r, accountLoginKey(user.Email))
+	if err != nil {
+		return err
+	}
+	defer cfg.finishLoginAttempt(r, attempt)
+
+	if user.PasswordHash != auth.NoPassword {
+		err = auth.CheckPassword(params.Password, user.PasswordHash)
+		if err != nil {
+			cfg.failLoginAttempt(r, attempt, uuid.NullUUID{UUID: user.ID, Valid: true})
+			return newError(codeInvalidCredentials, "Incorrect password", err)
+		}
+	}
+
+	totp, err := cfg.db.GetUserTotp(r.Context(), user.ID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return newError(codeInternal, "Couldn't get MFA settings", err)
+	}
+	if err == nil && totp.ConfirmedAt.Valid {
+		err = checkSecondFactor(r.Context(), cfg.db, user.ID, params.Code, params.RecoveryCode)
+		if errors.Is(err, errInvalidSecondFactor) {
+			cfg.failLoginAttempt(r, attempt, uuid.NullUUID{UUID: user.ID, Valid: true})
+			return newError(codeInvalidCredentials, "Invalid code", nil)
+		}
+		if err != nil {
+			return newError(codeInternal, "Couldn't check code", err)
+		}
+	}
+
+	deletedAt := time.Now()
+	err = cfg.withTx(r.Context(), func(q *database.Queries) error {
+		err := q.UpdateUserDeletedAt(r.Context(), database.UpdateUserDeletedAtParams{
+			ID:        user.ID,
+			DeletedAt: sql.NullTime{Time: deletedAt, Valid: true},
+		})
+		if err != nil {
+			return err
+		}
+
+		return q.RevokeAllRefreshTokensForUser(r.Context(), user.ID)
+	})
+	if err != nil {
+		return newError(codeInternal, "Couldn't delete account", err)
+	}
+
+	cfg.revokeAccessTokens(r.Context(), denylist.KindUser, user.ID.String())
+	cfg.recordAuditEvent(r, uuid.NullUUID{UUID: user.ID, Valid: true}, auditEventUserDeletionRequested, "")
+
+	respondWithJSON(w, http.StatusAccepted, AccountDeletionResponse{
+		PurgeAt: deletedAt.Add(accountDeletionGracePeriod),
+	})
+	return nil
+}
+
+// RunAccountPurge purges accounts whose deletion grace period is over, then
+// again every interval until ctx is done.
+func (cfg *Config) RunAccountPurge(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		cfg.purgeDeletedAccounts(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (cfg *Config) purgeDeletedAccounts(ctx context.Context) {
+	users, err := cfg.db.GetUsersDeletedBefore(ctx, sql.NullTime{
+		Time:  time.Now().Add(-accountDeletionGracePeriod),
+		Valid: true,
+	})
+	if err != nil {
+		log.Printf("Error getting deleted users to purge: %v", err)
+		return
+	}
+
+	for _, user := range users {
+		err := cfg.withTx(ctx, func(q *database.Queries) error {
+			return purgeUser(ctx, q, user.ID)
+		})
+		if err != nil {
+			log.Printf("Error purging deleted user %s: %v", user.ID, err)
+			continue
+		}
+		log.Printf("Purged deleted user %s", user.ID)
+	}
+}
+
+// purgeUser deletes a user and everything that belongs to them. Groups they
+// own or created would go with them, so each passes to its most senior other
+// member instead, and is only deleted when nobody else is in it.
+func purgeUser(ctx context.Context, q *database.Queries, userID uuid.UUID) error {
+	groups, err := q.GetGroupsOwnedOrAuthoredBy(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	for _, group := range groups {
+		successor, err := q.GetGroupSuccessor(ctx, database.GetGroupSuccessorParams{
+			GroupID: group.ID,
+			UserID:  userID,
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			if err := q.DeleteGroup(ctx, group.ID); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if successor.Role != groupRoleOwner {
+			_, err = q.UpdateGroupMemberRole(ctx, database.UpdateGroupMemberRoleParams{
+				GroupID: group.ID,
+				UserID:  successor.UserID,
+				Role:    groupRoleOwner,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		if group.AuthorID == userID {
+			err = q.UpdateGroupAuthor(ctx, database.UpdateGroupAuthorParams{
+				ID:       group.ID,
+				AuthorID: successor.UserID,
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return q.DeleteUser(ctx, userID)
+}
@@ -0,0 +1,357 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/potom-dev/backend/internal/database"
+	"github.com/potom-dev/backend/internal/denylist"
+	"github.com/potom-dev/backend/internal/validation"
+)
+
+type UpdateUserRoleParams struct {
+	Role string `json:"role"`
+}
+
+func (p UpdateUserRoleParams) Validate(v *validation.Validator) {
+	v.OneOf("role", p.Role, roleUser, roleAdmin)
+}
+
+// BootstrapAdmin makes the account registered with email an admin, so a new
+// deployment can get its first admin. It does nothing once there is an admin.
+func (cfg *Config) BootstrapAdmin(ctx context.Context, email string) error {
+	admins, err := cfg.db.CountAdmins(ctx)
+	if err != nil {
+		return err
+	}
+	if admins > 0 {
+		return nil
+	}
+
+	user, err := cfg.db.GetUserByEmail(ctx, email)
+	if errors.Is(err, sql.ErrNoRows) {
+		log.Printf("No account for bootstrap admin %s yet, sign up and restart to make it an admin", email)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	err = cfg.db.UpdateUserRole(ctx, database.UpdateUserRoleParams{
+		ID:   user.ID,
+		Role: roleAdmin,
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Made bootstrap admin %s an admin", email)
+	return nil
+}
+
+// loadManagedUser reads the userId path value and loads that user for an
+// admin to act on. It returns an error if the user doesn't exist, or is the
+// calling admin and self is false: admins can't lock themselves out.
+func (cfg *Config) loadManagedUser(r *http.Request, self bool) (database.User, error) {
+	userID, err := uuid.Parse(r.PathValue("userId"))
+	if err != nil {
+		return database.User{}, newError(codeInvalidRequest, "Invalid user ID", err)
+	}
+
+	if !self && userID == UserIDFromContext(r.Context()) {
+		return database.User{}, newError(codeInvalidRequest, "Admins can't do this to their own account", nil)
+	}
+
+	user, err := cfg.db.GetUserById(r.Context(), userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.User{}, newError(codeNotFound, "User not found", err)
+	}
+	if err != nil {
+		return database.User{}, newError(codeInternal, "Couldn't get user", err)
+	}
+
+	return user, nil
+}
+
+// recordAdminEvent audits an admin action on user. Events are recorded
+// against the admin, since the user may not exist afterwards.
+func (cfg *Config) recordAdminEvent(r *http.Request, eventType string, user database.User, detail string) {
+	adminID := uuid.NullUUID{UUID: UserIDFromContext(r.Context()), Valid: true}
+	cfg.recordAuditEvent(r, adminID, eventType, fmt.Sprintf("user %s%s", user.ID, detail))
+}
+
+// handlerDisableUser godoc
+//
+//	@Router		/users/{userId}/disable [post]
+//	@Summary	disable a user account
+//	@Description	Admin only. Signs the user out everywhere and stops them from signing in until the account is enabled again.
+//	@Tags		admin
+//	@Accept		json
+//	@Produce	json
+//	@Param		Authorization	header	string	true	"Bearer token"
+//	@Param		userId	path	string	true	"User ID"
+//	@Success	204		"No Content"
+//	@Failure	400		{object}	Problem
+//	@Failure	401		{object}	Problem
+//	@Failure	403		{object}	Problem
+//	@Failure	404		{object}	Problem
+//	@Failure	500		{object}	Problem
+//	@Security	BearerAuth
+func (cfg *Config) handlerDisableUser(w http.ResponseWriter, r *http.Request) error {
+	user, err := cfg.loadManagedUser(r, false)
+	if err != nil {
+		return err
+	}
+
+	if user.DisabledAt.Valid {
+		respondWithJSON(w, http.StatusNoContent, nil)
+		return nil
+	}
+
+	err = cfg.withTx(r.Context(), func(q *database.Queries) error {
+		err := q.UpdateUserDisabledAt(r.Context(), database.UpdateUserDisabledAtParams{
+			ID:         user.ID,
+			DisabledAt: sql.NullTime{Time: time.Now(), Valid: true},
+		})
+		if err != nil {
+			return err
+		}
+
+		return q.RevokeAllRefreshTokensForUser(r.Context(), user.ID)
+	})
+	if err != nil {
+		return newError(codeInternal, "Couldn't disable user", err)
+	}
+
+	cfg.revokeAccessTokens(r.Context(), denylist.KindUser, user.ID.String())
+	cfg.recordAdminEvent(r, auditEventUserDisabled, user, "")
+
+	respondWithJSON(w, http.StatusNoContent, nil)
+	return nil
+}
+
+// handlerEnableUser godoc
+//
+//	@Router		/users/{userId}/enable [post]
+//	@Summary	enable a disabled user account
+//	@Description	Admin only.
+//	@Tags		admin
+//	@Accept		json
+//	@Produce	json
+//	@Param		Authorization	header	string	true	"Bearer token"
+//	@Param		userId	path	string	true	"User ID"
+//	@Success	204		"No Content"
+//	@Failure	400		{object}	Problem
+//	@Failure	401		{object}	Problem
+//	@Failure	403		{object}	Problem
+//	@Failure	404		{object}	Problem
+//	@Failure	500		{object}	Problem
+//	@Security	BearerAuth
+func (cfg *Config) handlerEnableUser(w http.ResponseWriter, r *http.Request) error {
+	user, err := cfg.loadManagedUser(r, false)
+	if err != nil {
+		return err
+	}
+
+	if !user.DisabledAt.Valid {
+		respondWithJSON(w, http.StatusNoContent, nil)
+		return nil
+	}
+
+	err = cfg.db.UpdateUserDisabledAt(r.Context(), database.UpdateUserDisabledAtParams{
+		ID:         user.ID,
+		DisabledAt: sql.NullTime{},
+	})
+	if err != nil {
+		return newError(codeInternal, "Couldn't enable user", err)
+	}
+
+	cfg.recordAdminEvent(r, auditEventUserEnabled, user, "")
+
+	respondWithJSON(w, http.StatusNoContent, nil)
+	return nil
+}
+
+// handlerRestoreUser godoc
+//
+//	@Router		/users/{userId}/restore [post]
+//	@Summary	restore a deleted account before it is purged
+//	@Description	Admin only.
+//	@Tags		admin
+//	@Accept		json
+//	@Produce	json
+//	@Param		Authorization	header	string	true	"Bearer token"
+//	@Param		userId	path	string	true	"User ID"
+//	@Success	204		"No Content"
+//	@Failure	400		{object}	Problem
+//	@Failure	401		{object}	Problem
+//	@Failure	403		{object}	Problem
+//	@Failure	404		{object}	Problem
+//	@Failure	500		{object}	Problem
+//	@Security	BearerAuth
+func (cfg *Config) handlerRestoreUser(w http.ResponseWriter, r *http.Request) error {
+	user, err := cfg.loadManagedUser(r, false)
+	if err != nil {
+		return err
+	}
+
+	if !user.DeletedAt.Valid {
+		respondWithJSON(w, http.StatusNoContent, nil)
+		return nil
+	}
+
+	err = cfg.db.UpdateUserDeletedAt(r.Context(), database.UpdateUserDeletedAtParams{
+		ID:        user.ID,
+		DeletedAt: sql.NullTime{},
+	})
+	if err != nil {
+		return newError(codeInternal, "Couldn't restore user", err)
+	}
+
+	cfg.recordAdminEvent(r, auditEventUserRestored, user, "")
+
+	respondWithJSON(w, http.StatusNoContent, nil)
+	return nil
+}
+
+// handlerRequireUserPasswordReset godoc
+//
+//	@Router		/users/{userId}/password-reset [post]
+//	@Summary	force a user to reset their password
+//	@Description	Admin only. Signs the user out everywhere, deletes their API keys and emails them a reset link. Their old password stops working until they have chosen a new one.
+//	@Tags		admin
+//	@Accept		json
+//	@Produce	json
+//	@Param		Authorization	header	string	true	"Bearer token"
+//	@Param		userId	path	string	true	"User ID"
+//	@Success	204		"No Content"
+//	@Failure	400		{object}	Problem
+//	@Failure	401		{object}	Problem
+//	@Failure	403		{object}	Problem
+//	@Failure	404		{object}	Problem
+//	@Failure	500		{object}	Problem
+//	@Security	BearerAuth
+func (cfg *Config) handlerRequireUserPasswordReset(w http.ResponseWriter, r *http.Request) error {
+	user, err := cfg.loadManagedUser(r, true)
+	if err != nil {
+		return err
+	}
+
+	err = cfg.withTx(r.Context(), func(q *database.Queries) error {
+		err := q.RequireUserPasswordReset(r.Context(), user.ID)
+		if err != nil {
+			return err
+		}
+
+		_, err = revokePasswordCredentials(r.Context(), q, user.ID, uuid.NullUUID{})
+		return err
+	})
+	if err != nil {
+		return newError(codeInternal, "Couldn't require password reset", err)
+	}
+
+	cfg.revokeAccessTokens(r.Context(), denylist.KindUser, user.ID.String())
+	cfg.recordAdminEvent(r, auditEventUserPasswordReset, user, "")
+
+	if err := cfg.sendPasswordReset(r.Context(), user.ID, user.Email); err != nil {
+		return newError(codeInternal, "Couldn't create reset token", err)
+	}
+
+	respondWithJSON(w, http.StatusNoContent, nil)
+	return nil
+}
+
+// handlerUpdateUserRole godoc
+//
+//	@Router		/users/{userId}/role [put]
+//	@Summary	change a user's platform role
+//	@Description	Admin only. The role is either user or admin.
+//	@Tags		admin
+//	@Accept		json
+//	@Produce	json
+//	@Param		Authorization	header	string	true	"Bearer token"
+//	@Param		userId	path	string					true	"User ID"
+//	@Param		body	body	UpdateUserRoleParams	true	"New role"
+//	@Success	204		"No Content"
+//	@Failure	400		{object}	Problem
+//	@Failure	401		{object}	Problem
+//	@Failure	403		{object}	Problem
+//	@Failure	404		{object}	Problem
+//	@Failure	422		{object}	Problem
+//	@Failure	500		{object}	Problem
+//	@Security	BearerAuth
+func (cfg *Config) handlerUpdateUserRole(w http.ResponseWriter, r *http.Request) error {
+	user, err := cfg.loadManagedUser(r, false)
+	if err != nil {
+		return err
+	}
+
+	params := UpdateUserRoleParams{}
+	if err := decodeParams(w, r, &params); err != nil {
+		return err
+	}
+
+	if params.Role == user.Role {
+		respondWithJSON(w, http.StatusNoContent, nil)
+		return nil
+	}
+
+	err = cfg.db.UpdateUserRole(r.Context(), database.UpdateUserRoleParams{
+		ID:   user.ID,
+		Role: params.Role,
+	})
+	if err != nil {
+		return newError(codeInternal, "Couldn't update role", err)
+	}
+
+	// Access tokens carry the role, so make the user refresh to pick up the
+	// new one.
+	cfg.revokeAccessTokens(r.Context(), denylist.KindUser, user.ID.String())
+	cfg.recordAdminEvent(r, auditEventUserRoleChanged, user, " to "+params.Role)
+
+	respondWithJSON(w, http.StatusNoContent, nil)
+	return nil
+}
+
+// handlerDeleteUser godoc
+//
+//	@Router		/users/{userId} [delete]
+//	@Summary	delete a user
+//	@Description	Admin only. The account is deleted at once, without a grace period. Groups the user owns pass to their most senior other member, or are deleted if they have no other members.
+//	@Tags		admin
+//	@Accept		json
+//	@Produce	json
+//	@Param		Authorization	header	string	true	"Bearer token"
+//	@Param		userId	path	string	true	"User ID"
+//	@Success	204		"No Content"
+//	@Failure	400		{object}	Problem
+//	@Failure	401		{object}	Problem
+//	@Failure	403		{object}	Problem
+//	@Failure	404		{object}	Problem
+//	@Failure	500		{object}	Problem
+//	@Security	BearerAuth
+func (cfg *Config) handlerDeleteUser(w http.ResponseWriter, r *http.Request) error {
+	user, err := cfg.loadManagedUser(r, false)
+	if err != nil {
+		return err
+	}
+
+	err = cfg.withTx(r.Context(), func(q *database.Queries) error {
+		return purgeUser(r.Context(), q, user.ID)
+	})
+	if err != nil {
+		return newError(codeInternal, "Couldn't delete user", err)
+	}
+
+	cfg.revokeAccessTokens(r.Context(), denylist.KindUser, user.ID.String())
+	cfg.recordAdminEvent(r, auditEventUserDeleted, user, " ("+user.Email+")")
+
+	respondWithJSON(w, http.StatusNoContent, nil)
+	return nil
+}
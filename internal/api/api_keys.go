@@ -0,0 +1,276 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/potom-dev/backend/internal/auth"
+	"github.com/potom-dev/backend/internal/database"
+	"github.com/potom-dev/backend/internal/pagination"
+	"github.com/potom-dev/backend/internal/validation"
+)
+
+// Scopes an API key can be granted.
+const (
+	scopeGroupsRead  = "groups:read"
+	scopeGroupsWrite = "groups:write"
+	scopeUsersRead   = "users:read"
+)
+
+var apiKeyScopes = []string{scopeGroupsRead, scopeGroupsWrite, scopeUsersRead}
+
+const maxApiKeyNameLength = 100
+
+// API keys expire like any other credential, so that a forgotten key doesn't
+// grant access forever.
+const (
+	defaultApiKeyLifetime = time.Hour * 24 * 90
+	maxApiKeyLifetime     = time.Hour * 24 * 365
+)
+
+// apiKeyLastUsedPrecision is how stale an API key's last use may get before
+// it is written again, so busy scripts don't cause a write per request.
+const apiKeyLastUsedPrecision = time.Minute
+
+type CreateApiKeyParams struct {
+	Name         string   `json:"name"`
+	Scopes       []string `json:"scopes"`
+	ExpiresInSec int64    `json:"expires_in_seconds,omitempty"`
+	// Password is required if the account has one, so that a stolen session
+	// can't be turned into a long-lived key.
+	Password string `json:"password,omitempty"`
+}
+
+func (p CreateApiKeyParams) Validate(v *validation.Validator) {
+	v.Required("name", p.Name)
+	v.MaxLength("name", strings.TrimSpace(p.Name), maxApiKeyNameLength)
+	v.Check(len(p.Scopes) > 0, "scopes", "must not be empty")
+	for i, scope := range p.Scopes {
+		v.OneOf(fmt.Sprintf("scopes[%d]", i), scope, apiKeyScopes...)
+	}
+	v.NonNegative("expires_in_seconds", p.ExpiresInSec)
+}
+
+type ApiKey struct {
+	Id         uuid.UUID  `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+type CreateApiKeyResponse struct {
+	ApiKey
+	Key string `json:"key"`
+}
+
+func apiKeyFromDB(key database.ApiKey) ApiKey {
+	resp := ApiKey{
+		Id:        key.ID,
+		Name:      key.Name,
+		Prefix:    key.Prefix,
+		Scopes:    key.Scopes,
+		CreatedAt: key.CreatedAt,
+	}
+	if key.ExpiresAt.Valid {
+		resp.ExpiresAt = &key.ExpiresAt.Time
+	}
+	if key.LastUsedAt.Valid {
+		resp.LastUsedAt = &key.LastUsedAt.Time
+	}
+	return resp
+}
+
+// authenticateApiKey returns the caller an API key belongs to, or an error if
+// the key isn't valid.
+func (cfg *Config) authenticateApiKey(r *http.Request, token string) (Principal, error) {
+	key, err := cfg.db.GetApiKeyByHash(r.Context(), auth.HashToken(token))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Principal{}, newError(codeInvalidToken, "Invalid API key", nil)
+	}
+	if err != nil {
+		return Principal{}, newError(codeInternal, "Couldn't get API key", err)
+	}
+
+	now := time.Now()
+	if key.ExpiresAt.Valid && key.ExpiresAt.Time.Before(now) {
+		return Principal{}, newError(codeInvalidToken, "API key expired", nil)
+	}
+	if key.DeletedAt.Valid {
+		return Principal{}, newError(codeAccountDeleted, "Account has been deleted", nil)
+	}
+	if key.DisabledAt.Valid {
+		return Principal{}, newError(codeAccountDisabled, "Account is disabled", nil)
+	}
+	if key.PasswordResetRequired {
+		return Principal{}, newError(codePasswordResetRequired, "Password reset required", nil)
+	}
+
+	if !key.LastUsedAt.Valid || now.Sub(key.LastUsedAt.Time) > apiKeyLastUsedPrecision {
+		err = cfg.db.UpdateApiKeyLastUsed(r.Context(), database.UpdateApiKeyLastUsedParams{
+			ID:         key.ID,
+			LastUsedAt: sql.NullTime{Time: now, Valid: true},
+		})
+		if err != nil {
+			log.Printf("Error updating last use of API key %s: %v", key.ID, err)
+		}
+	}
+
+	return Principal{
+		UserID:        key.UserID,
+		EmailVerified: key.EmailVerifiedAt.Valid,
+		ApiKeyID:      key.ID,
+		Scopes:        key.Scopes,
+	}, nil
+}
+
+// handlerCreateApiKey godoc
+//
+//	@Router		/users/me/api-keys [post]
+//	@Summary	create an API key for the caller
+//	@Description	Requires the account password, if it has one. The key is only returned in this response. Scopes are any of groups:read, groups:write and users:read. Keys expire after 90 days unless expires_in_seconds asks for another lifetime, of at most a year, and are deleted when the password changes.
+//	@Tags		api-keys
+//	@Accept		json
+//	@Produce	json
+//	@Param		Authorization	header	string	true	"Bearer token"
+//	@Param		body	body		CreateApiKeyParams	true	"API key parameters"
+//	@Success	201		{object}	CreateApiKeyResponse
+//	@Failure	400		{object}	Problem
+//	@Failure	401		{object}	Problem
+//	@Failure	422		{object}	Problem
+//	@Failure	500		{object}	Problem
+//	@Security	BearerAuth
+func (cfg *Config) handlerCreateApiKey(w http.ResponseWriter, r *http.Request) error {
+	params := CreateApiKeyParams{}
+	if err := decodeParams(w, r, &params); err != nil {
+		return err
+	}
+
+	user, err := cfg.db.GetUserById(r.Context(), UserIDFromContext(r.Context()))
+	if err != nil {
+		return newError(codeInternal, "Couldn't get user", err)
+	}
+
+	if user.PasswordHash != auth.NoPassword {
+		err = auth.CheckPassword(params.Password, user.PasswordHash)
+		if err != nil {
+			return newError(codeInvalidCredentials, "Incorrect password", err)
+		}
+	}
+
+	scopes := slices.Clone(params.Scopes)
+	slices.Sort(scopes)
+	scopes = slices.Compact(scopes)
+
+	lifetime := defaultApiKeyLifetime
+	if params.ExpiresInSec > 0 {
+		lifetime = min(time.Duration(params.ExpiresInSec)*time.Second, maxApiKeyLifetime)
+	}
+
+	key, prefix, err := auth.MakeAPIKey()
+	if err != nil {
+		return newError(codeInternal, "Couldn't create API key", err)
+	}
+
+	apiKey, err := cfg.db.CreateApiKey(r.Context(), database.CreateApiKeyParams{
+		UserID:    user.ID,
+		Name:      strings.TrimSpace(params.Name),
+		Prefix:    prefix,
+		KeyHash:   auth.HashToken(key),
+		Scopes:    scopes,
+		ExpiresAt: sql.NullTime{Time: time.Now().Add(lifetime), Valid: true},
+	})
+	if err != nil {
+		return newError(codeInternal, "Couldn't create API key", err)
+	}
+
+	respondWithJSON(w, http.StatusCreated, CreateApiKeyResponse{
+		ApiKey: apiKeyFromDB(apiKey),
+		Key:    key,
+	})
+	return nil
+}
+
+// handlerGetApiKeys godoc
+//
+//	@Router		/users/me/api-keys [get]
+//	@Summary	list the caller's API keys
+//	@Tags		api-keys
+//	@Accept		json
+//	@Produce	json
+//	@Param		Authorization	header	string	true	"Bearer token"
+//	@Param		limit	query	int		false	"Page size, 1 to 200"	default(50)
+//	@Param		cursor	query	string	false	"next_cursor of the previous page"
+//	@Success	200	{object}	pagination.Page[ApiKey]
+//	@Failure	400	{object}	Problem
+//	@Failure	401	{object}	Problem
+//	@Failure	500	{object}	Problem
+//	@Security	BearerAuth
+func (cfg *Config) handlerGetApiKeys(w http.ResponseWriter, r *http.Request) error {
+	page, err := parsePage(r, "created_at")
+	if err != nil {
+		return err
+	}
+
+	keys, err := cfg.db.ListApiKeys(r.Context(), database.ListApiKeysParams{
+		UserID:         UserIDFromContext(r.Context()),
+		AfterCreatedAt: page.AfterTime(),
+		AfterID:        page.AfterID(),
+		Limit:          page.FetchLimit(),
+	})
+	if err != nil {
+		return newError(codeInternal, "Couldn't get API keys", err)
+	}
+
+	cursor := func(key database.ApiKey) pagination.Cursor {
+		return pagination.Cursor{Time: &key.CreatedAt, ID: key.ID}
+	}
+
+	respondWithJSON(w, http.StatusOK, pagination.NewPage(keys, page, cursor, apiKeyFromDB))
+	return nil
+}
+
+// handlerRevokeApiKey godoc
+//
+//	@Router		/users/me/api-keys/{keyId} [delete]
+//	@Summary	revoke one of the caller's API keys
+//	@Tags		api-keys
+//	@Accept		json
+//	@Produce	json
+//	@Param		Authorization	header	string	true	"Bearer token"
+//	@Param		keyId	path	string	true	"API key ID"
+//	@Success	204		"No Content"
+//	@Failure	400		{object}	Problem
+//	@Failure	401		{object}	Problem
+//	@Failure	404		{object}	Problem
+//	@Failure	500		{object}	Problem
+//	@Security	BearerAuth
+func (cfg *Config) handlerRevokeApiKey(w http.ResponseWriter, r *http.Request) error {
+	keyID, err := uuid.Parse(r.PathValue("keyId"))
+	if err != nil {
+		return newError(codeInvalidRequest, "Invalid API key ID", err)
+	}
+
+	deleted, err := cfg.db.DeleteApiKey(r.Context(), database.DeleteApiKeyParams{
+		ID:     keyID,
+		UserID: UserIDFromContext(r.Context()),
+	})
+	if err != nil {
+		return newError(codeInternal, "Couldn't revoke API key", err)
+	}
+	if deleted == 0 {
+		return newError(codeNotFound, "API key not found", nil)
+	}
+
+	respondWithJSON(w, http.StatusNoContent, nil)
+	return nil
+}
@@ -0,0 +1,35 @@
+package api
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/potom-dev/backend/internal/database"
+)
+
+const (
+	auditEventLoginLockedOut    = "login.locked_out"
+	auditEventUserDisabled      = "user.disabled"
+	auditEventUserEnabled       = "user.enabled"
+	auditEventUserPasswordReset = "user.password_reset_required"
+	auditEventUserRoleChanged   = "user.role_changed"
+	auditEventUserDeleted       = "user.deleted"
+	auditEventUserRestored      = "user.restored"
+
+	auditEventUserDeletionRequested = "user.deletion_requested"
+)
+
+// recordAuditEvent stores a security relevant event. Errors are logged rather
+// than returned, so auditing never breaks the request being audited.
+func (cfg *Config) recordAuditEvent(r *http.Request, userID uuid.NullUUID, eventType, detail string) {
+	err := cfg.db.CreateAuditEvent(r.Context(), database.CreateAuditEventParams{
+		UserID:    userID,
+		EventType: eventType,
+		IpAddress: nullString(clientIP(r)),
+		Detail:    nullString(detail),
+	})
+	if err != nil {
+		log.Printf("Error recording %s audit event: %v", eventType, err)
+	}
+}
@@ -1,21 +1,38 @@
 package api
 
 import (
-	"encoding/json"
+	"context"
+	"database/sql"
+	"errors"
 	"log"
 	"net/http"
 	"time"
 
 	"github.com/google/uuid"
-	"github.com/markbates/goth/gothic"
 	"github.com/potom-dev/backend/internal/auth"
 	"github.com/potom-dev/backend/internal/database"
+	"github.com/potom-dev/backend/internal/denylist"
+	"github.com/potom-dev/backend/internal/validation"
 )
 
+const refreshTokenLifetime = time.Hour * 24 * 60
+
+const maxDeviceLabelLength = 100
+
+var errRefreshTokenReused = errors.New("refresh token already rotated")
+
 type LoginParams struct {
 	Email        string `json:"email"`
 	Password     string `json:"password"`
 	ExpiresInSec int64  `json:"expires_in_seconds,omitempty"`
+	DeviceLabel  string `json:"device_label,omitempty"`
+}
+
+func (p LoginParams) Validate(v *validation.Validator) {
+	v.Required("email", p.Email)
+	v.Required("password", p.Password)
+	v.NonNegative("expires_in_seconds", p.ExpiresInSec)
+	v.MaxLength("device_label", p.DeviceLabel, maxDeviceLabelLength)
 }
 
 type LoginResponse struct {
@@ -23,10 +40,15 @@ type LoginResponse struct {
 	Email        string    `json:"email"`
 	Token        string    `json:"token"`
 	RefreshToken string    `json:"refresh_token"`
+	SessionId    uuid.UUID `json:"session_id"`
+	ExpiresIn    int64     `json:"expires_in"`
 }
 
 type RefreshResponse struct {
-	Token string `json:"token"`
+	Token        string    `json:"token"`
+	RefreshToken string    `json:"refresh_token"`
+	SessionId    uuid.UUID `json:"session_id"`
+	ExpiresIn    int64     `json:"expires_in"`
 }
 
 // handlerLogin godoc
@@ -38,107 +60,267 @@ type RefreshResponse struct {
 //	@Produce	json
 //	@Param		body	body		LoginParams	true	"Login parameters"
 //	@Success	200		{object}	LoginResponse
-//	@Failure	401		{object}	ErrorResponse
-//	@Failure	500		{object}	ErrorResponse
-func (cfg *Config) handlerLogin(w http.ResponseWriter, r *http.Request) {
-	var params LoginParams
-	err := json.NewDecoder(r.Body).Decode(&params)
+//	@Success	202		{object}	MfaChallengeResponse
+//	@Failure	400		{object}	Problem
+//	@Failure	401		{object}	Problem
+//	@Failure	403		{object}	Problem
+//	@Failure	422		{object}	Problem
+//	@Failure	429		{object}	Problem
+//	@Failure	500		{object}	Problem
+func (cfg *Config) handlerLogin(w http.ResponseWriter, r *http.Request) error {
+	params := LoginParams{}
+	if err := decodeParams(w, r, &params); err != nil {
+		return err
+	}
+
+	attempt, err := cfg.startLoginAttempt(w, r, accountLoginKey(params.Email))
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters", err)
-		return
+		return err
 	}
+	defer cfg.finishLoginAttempt(r, attempt)
 
 	user, err := cfg.db.GetUserByEmail(r.Context(), params.Email)
+	if errors.Is(err, sql.ErrNoRows) {
+		// Take as long as a wrong password would, so the response time
+		// doesn't tell whether the account exists.
+		auth.CheckDummyPassword(params.Password)
+		cfg.failLoginAttempt(r, attempt, uuid.NullUUID{})
+		return newError(codeInvalidCredentials, "Incorrect email or password", nil)
+	}
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Incorrect email or password", err)
-		return
+		return newError(codeInternal, "Couldn't get user", err)
 	}
 
 	err = auth.CheckPassword(params.Password, user.PasswordHash)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Incorrect email or password", err)
-		return
+		cfg.failLoginAttempt(r, attempt, uuid.NullUUID{UUID: user.ID, Valid: true})
+		return newError(codeInvalidCredentials, "Incorrect email or password", err)
 	}
 
-	token, err := auth.MakeJWT(user.ID, cfg.jwtSecret)
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't create JWT", err)
-		return
+	if user.PasswordResetRequired {
+		return newError(codePasswordResetRequired, "Password reset required, check your email for a reset link", nil)
 	}
 
-	refresh, err := auth.MakeRefreshToken()
+	return cfg.completeLogin(w, r, user, params.DeviceLabel, time.Duration(params.ExpiresInSec)*time.Second)
+}
+
+// completeLogin finishes a sign in once the user has passed the first
+// factor. Users with two-factor authentication enabled get a challenge to
+// answer at /mfa/verify instead of tokens, and their failed logins are only
+// forgotten once they answer it. expiresIn is the access token lifetime the
+// client asked for, if any.
+func (cfg *Config) completeLogin(w http.ResponseWriter, r *http.Request, user database.User, deviceLabel string, expiresIn time.Duration) error {
+	if err := checkAccountActive(user); err != nil {
+		return err
+	}
+
+	totp, err := cfg.db.GetUserTotp(r.Context(), user.ID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return newError(codeInternal, "Couldn't get MFA settings", err)
+	}
+
+	if err == nil && totp.ConfirmedAt.Valid {
+		mfaToken, err := cfg.startMfaChallenge(r.Context(), user.ID, deviceLabel, expiresIn)
+		if err != nil {
+			return newError(codeInternal, "Couldn't create MFA challenge", err)
+		}
+
+		respondWithJSON(w, http.StatusAccepted, MfaChallengeResponse{
+			MfaRequired: true,
+			MfaToken:    mfaToken,
+		})
+		return nil
+	}
+
+	return cfg.respondWithNewSession(w, r, user, deviceLabel, expiresIn)
+}
+
+// respondWithNewSession starts a new session for user and responds with its
+// access and refresh tokens.
+func (cfg *Config) respondWithNewSession(w http.ResponseWriter, r *http.Request, user database.User, deviceLabel string, expiresIn time.Duration) error {
+	if err := checkAccountActive(user); err != nil {
+		return err
+	}
+
+	cfg.resetLoginFailures(r.Context(), user)
+
+	sessionID := uuid.New()
+	lifetime := cfg.tokenLifetime.Clamp(expiresIn)
+
+	token, err := cfg.makeAccessToken(user, sessionID, lifetime)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't create refresh token", err)
-		return
+		return newError(codeInternal, "Couldn't create JWT", err)
 	}
 
-	refreshToken, err := cfg.db.CreateRefreshToken(r.Context(), database.CreateRefreshTokenParams{
-		Token:     refresh,
-		UserID:    user.ID,
-		ExpiresAt: time.Now().Add(time.Hour * 24 * 60),
-	})
+	refresh, err := issueRefreshToken(r, cfg.db, user.ID, sessionID, deviceLabel)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't create refresh token", err)
-		return
+		return newError(codeInternal, "Couldn't create refresh token", err)
 	}
 
 	respondWithJSON(w, http.StatusOK, LoginResponse{
 		Id:           user.ID,
 		Email:        user.Email,
 		Token:        token,
-		RefreshToken: refreshToken.Token,
+		RefreshToken: refresh,
+		SessionId:    sessionID,
+		ExpiresIn:    int64(lifetime.Seconds()),
 	})
+	return nil
+}
+
+// checkAccountActive returns an error if user's account has been deleted, or
+// disabled by an admin.
+func checkAccountActive(user database.User) error {
+	if user.DeletedAt.Valid {
+		return newError(codeAccountDeleted, "Account has been deleted", nil)
+	}
+	if user.DisabledAt.Valid {
+		return newError(codeAccountDisabled, "Account is disabled", nil)
+	}
+	return nil
+}
+
+// makeAccessToken signs an access token for user in the given session.
+func (cfg *Config) makeAccessToken(user database.User, sessionID uuid.UUID, lifetime time.Duration) (string, error) {
+	return auth.MakeJWT(auth.TokenSubject{
+		UserID:        user.ID,
+		SessionID:     sessionID,
+		Roles:         []string{user.Role},
+		EmailVerified: user.EmailVerifiedAt.Valid,
+	}, lifetime, cfg.jwtKeys)
 }
 
 // handlerRefresh godoc
 //
 //	@Router		/refresh [post]
-//	@Summary	refresh access token
+//	@Summary	refresh access token and rotate the refresh token
 //	@Tags		auth
 //	@Accept		json
 //	@Produce	json
 //	@Param		Authorization	header	string	true	"Bearer token"
 //	@Success	200		{object}	RefreshResponse
-//	@Failure	401		{object}	ErrorResponse
-//	@Failure	500		{object}	ErrorResponse
-func (cfg *Config) handlerRefresh(w http.ResponseWriter, r *http.Request) {
+//	@Failure	401		{object}	Problem
+//	@Failure	403		{object}	Problem
+//	@Failure	500		{object}	Problem
+func (cfg *Config) handlerRefresh(w http.ResponseWriter, r *http.Request) error {
 	refresh, err := auth.GetBearerToken(r.Header)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't get bearer token", err)
-		return
+		return newError(codeUnauthenticated, "Couldn't get bearer token", err)
 	}
 
-	refreshToken, err := cfg.db.GetRefreshToken(r.Context(), refresh)
+	refreshToken, err := cfg.db.GetRefreshTokenByHash(r.Context(), auth.HashToken(refresh))
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Invalid refresh token", err)
-		return
+		return newError(codeInvalidToken, "Invalid refresh token", err)
 	}
 
 	if refreshToken.RevokedAt.Valid {
-		respondWithError(w, http.StatusUnauthorized, "Refresh token revoked", nil)
-		return
+		if refreshToken.ReplacedBy.Valid {
+			cfg.revokeRefreshTokenFamily(r, refreshToken)
+			return newError(codeInvalidToken, "Refresh token reuse detected", nil)
+		}
+		return newError(codeInvalidToken, "Refresh token revoked", nil)
 	}
 
 	if refreshToken.ExpiresAt.Before(time.Now()) {
-		respondWithError(w, http.StatusUnauthorized, "Refresh token expired", nil)
-		return
+		return newError(codeInvalidToken, "Refresh token expired", nil)
 	}
 
 	user, err := cfg.db.GetUserById(r.Context(), refreshToken.UserID)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "User not found", err)
-		return
+		return newError(codeInvalidToken, "User not found", err)
+	}
+	if err := checkAccountActive(user); err != nil {
+		return err
+	}
+
+	token, err := cfg.makeAccessToken(user, refreshToken.FamilyID, cfg.tokenLifetime.Default)
+	if err != nil {
+		return newError(codeInternal, "Couldn't create JWT", err)
 	}
 
-	token, err := auth.MakeJWT(user.ID, cfg.jwtSecret)
+	var newRefresh string
+	err = cfg.withTx(r.Context(), func(q *database.Queries) error {
+		var err error
+		newRefresh, err = issueRefreshToken(r, q, user.ID, refreshToken.FamilyID, refreshToken.DeviceLabel.String)
+		if err != nil {
+			return err
+		}
+
+		_, err = q.RotateRefreshToken(r.Context(), database.RotateRefreshTokenParams{
+			ID:         refreshToken.ID,
+			ReplacedBy: sql.NullString{String: auth.HashToken(newRefresh), Valid: true},
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			// Someone else rotated this token between our read and write.
+			return errRefreshTokenReused
+		}
+		return err
+	})
+	if errors.Is(err, errRefreshTokenReused) {
+		cfg.revokeRefreshTokenFamily(r, refreshToken)
+		return newError(codeInvalidToken, "Refresh token reuse detected", nil)
+	}
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't create JWT", err)
-		return
+		return newError(codeInternal, "Couldn't rotate refresh token", err)
 	}
 
 	respondWithJSON(w, http.StatusOK, RefreshResponse{
-		Token: token,
+		Token:        token,
+		RefreshToken: newRefresh,
+		SessionId:    refreshToken.FamilyID,
+		ExpiresIn:    int64(cfg.tokenLifetime.Default.Seconds()),
 	})
+	return nil
+}
+
+// issueRefreshToken stores a new refresh token in the given session (token
+// family) and returns the plaintext token. Only its hash is persisted.
+func issueRefreshToken(r *http.Request, q *database.Queries, userID, sessionID uuid.UUID, deviceLabel string) (string, error) {
+	refresh, err := auth.MakeRefreshToken()
+	if err != nil {
+		return "", err
+	}
+
+	_, err = q.CreateRefreshToken(r.Context(), database.CreateRefreshTokenParams{
+		TokenHash:   auth.HashToken(refresh),
+		UserID:      userID,
+		ExpiresAt:   time.Now().Add(refreshTokenLifetime),
+		FamilyID:    sessionID,
+		UserAgent:   nullString(r.UserAgent()),
+		IpAddress:   nullString(clientIP(r)),
+		DeviceLabel: nullString(deviceLabel),
+		LastUsedAt:  sql.NullTime{Time: time.Now(), Valid: true},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return refresh, nil
+}
+
+// revokeRefreshTokenFamily logs out every token descended from the same
+// login as refreshToken. It is called when an already rotated token is
+// presented again, which means the chain has leaked.
+func (cfg *Config) revokeRefreshTokenFamily(r *http.Request, refreshToken database.RefreshToken) {
+	log.Printf("Refresh token reuse detected for user %s, revoking family %s", refreshToken.UserID, refreshToken.FamilyID)
+
+	err := cfg.db.RevokeRefreshTokenFamily(r.Context(), refreshToken.FamilyID)
+	if err != nil {
+		log.Printf("Error revoking refresh token family %s: %v", refreshToken.FamilyID, err)
+	}
+
+	cfg.revokeAccessTokens(r.Context(), denylist.KindSession, refreshToken.FamilyID.String())
+}
+
+// revokeAccessTokens denies access tokens matching kind and value for as long
+// as any of them can still be valid. Failures are logged rather than returned:
+// the refresh tokens are already revoked by then, so the access tokens die
+// with their expiry at the latest.
+func (cfg *Config) revokeAccessTokens(ctx context.Context, kind denylist.Kind, value string) {
+	err := cfg.denylist.Revoke(ctx, kind, value, time.Now().Add(cfg.tokenLifetime.Max))
+	if err != nil {
+		log.Printf("Error revoking access tokens for %s %s: %v", kind, value, err)
+	}
 }
 
 // handlerRevokeRefresh godoc
@@ -150,50 +332,27 @@ func (cfg *Config) handlerRefresh(w http.ResponseWriter, r *http.Request) {
 //	@Produce	json
 //	@Param		Authorization	header	string	true	"Bearer token"
 //	@Success	204		"No Content"
-//	@Failure	401		{object}	ErrorResponse
-//	@Failure	500		{object}	ErrorResponse
-func (cfg *Config) handlerRevokeRefresh(w http.ResponseWriter, r *http.Request) {
+//	@Failure	401		{object}	Problem
+//	@Failure	500		{object}	Problem
+func (cfg *Config) handlerRevokeRefresh(w http.ResponseWriter, r *http.Request) error {
 	refresh, err := auth.GetBearerToken(r.Header)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't get bearer token", err)
-		return
+		return newError(codeUnauthenticated, "Couldn't get bearer token", err)
 	}
 
-	err = cfg.db.RevokeRefreshToken(r.Context(), refresh)
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't revoke refresh token", err)
-		return
+	sessionID, err := cfg.db.RevokeRefreshToken(r.Context(), auth.HashToken(refresh))
+	if errors.Is(err, sql.ErrNoRows) {
+		// Unknown tokens are already as revoked as they can be.
+		respondWithJSON(w, http.StatusNoContent, nil)
+		return nil
 	}
-
-	respondWithJSON(w, http.StatusNoContent, nil)
-}
-
-func (cfg *Config) handlerOauthCallback(w http.ResponseWriter, r *http.Request) {
-	// TODO: uncomment when moving to chi
-	// provider := chi.URLParam(r, "provider")
-	// r = r.WithContext(context.WithValue(r.Context(), "provider", provider))
-
-	user, err := gothic.CompleteUserAuth(w, r)
 	if err != nil {
-		log.Println(err)
-		return
+		return newError(codeInternal, "Couldn't revoke refresh token", err)
 	}
 
-	log.Println(user)
-
-	http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
-}
+	// Logging out ends the session, so its access tokens stop working too.
+	cfg.revokeAccessTokens(r.Context(), denylist.KindSession, sessionID.String())
 
-func (cfg *Config) handlerOauthLogout(w http.ResponseWriter, r *http.Request) {
-	gothic.Logout(w, r)
-	w.Header().Set("Location", "/")
-	http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
-}
-
-func (cfg *Config) handlerOauthAuth(w http.ResponseWriter, r *http.Request) {
-	if _, err := gothic.CompleteUserAuth(w, r); err == nil {
-		http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
-	} else {
-		gothic.BeginAuthHandler(w, r)
-	}
+	respondWithJSON(w, http.StatusNoContent, nil)
+	return nil
 }
@@ -1,23 +1,86 @@
 package api
 
 import (
+	"context"
+	"database/sql"
 	"net/http"
+	"net/netip"
+	"strings"
+	"sync"
 	"sync/atomic"
 
+	"github.com/potom-dev/backend/internal/auth"
+	"github.com/potom-dev/backend/internal/config"
 	"github.com/potom-dev/backend/internal/database"
+	"github.com/potom-dev/backend/internal/denylist"
+	"github.com/potom-dev/backend/internal/lockout"
+	"github.com/potom-dev/backend/internal/mailer"
 )
 
 type Config struct {
 	fileserverHits atomic.Int32
+	sqlDB          *sql.DB
 	db             *database.Queries
-	jwtSecret      string
+	jwtKeys        *auth.KeyRing
+	tokenLifetime  auth.TokenLifetime
+	denylist       *denylist.Denylist
+	mailer         mailer.Mailer
+	mailInFlight   sync.WaitGroup
+	appURL         string
+	apiURL         string
+	platform       string
+	// schemaVersion is the migration version the database should be at.
+	schemaVersion int64
+	shuttingDown  atomic.Bool
+
+	accountLimiter *lockout.Limiter
+	ipLimiter      *lockout.Limiter
+	// The mail limiters throttle endpoints that send email on request.
+	mailAddressLimiter *lockout.Limiter
+	mailIPLimiter      *lockout.Limiter
+
+	requireVerifiedEmailForGroups bool
+
+	// trustedProxies are the reverse proxies whose X-Forwarded-For is
+	// believed.
+	trustedProxies []netip.Prefix
 }
 
-func NewConfig(db *database.Queries, jwtSecret string) *Config {
+// NewConfig creates the API configuration from the server configuration
+// conf. Failed logins and requests for email are tracked in lockoutStore. Access tokens are checked
+// against tokenDenylist, so they can be revoked before they expire.
+// Readiness fails while the database is behind schemaVersion, the newest
+// migration.
+func NewConfig(conf config.Config, db *sql.DB, jwtKeys *auth.KeyRing, tokenDenylist *denylist.Denylist, mail mailer.Mailer, lockoutStore lockout.Store, schemaVersion int64) *Config {
+	// Validate has already rejected unparsable proxies.
+	trustedProxies, _ := conf.HTTP.TrustedProxyPrefixes()
+
 	return &Config{
 		fileserverHits: atomic.Int32{},
-		db:             db,
-		jwtSecret:      jwtSecret,
+		sqlDB:          db,
+		db:             database.New(db),
+		jwtKeys:        jwtKeys,
+		tokenLifetime: auth.TokenLifetime{
+			Default: conf.Auth.AccessTokenTTL,
+			Max:     conf.Auth.AccessTokenMaxTTL,
+		},
+		denylist: tokenDenylist,
+		mailer:   mail,
+		appURL:   strings.TrimRight(conf.AppURL, "/"),
+		apiURL:   strings.TrimRight(conf.APIURL, "/"),
+		platform: conf.Platform,
+
+		schemaVersion: schemaVersion,
+
+		accountLimiter: lockout.NewLimiter(lockoutStore, accountLoginPolicy),
+		ipLimiter:      lockout.NewLimiter(lockoutStore, ipLoginPolicy),
+
+		mailAddressLimiter: lockout.NewLimiter(lockoutStore, mailAddressPolicy),
+		mailIPLimiter:      lockout.NewLimiter(lockoutStore, mailIPPolicy),
+
+		requireVerifiedEmailForGroups: conf.Auth.RequireVerifiedEmail,
+
+		trustedProxies: trustedProxies,
 	}
 }
 
@@ -27,3 +90,18 @@ func (cfg *Config) MiddlewareMetricsInc(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// withTx runs fn inside a database transaction, committing if fn returns nil.
+func (cfg *Config) withTx(ctx context.Context, fn func(q *database.Queries) error) error {
+	tx, err := cfg.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(cfg.db.WithTx(tx)); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
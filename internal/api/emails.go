@@ -0,0 +1,90 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	"github.com/potom-dev/backend/internal/mailer"
+)
+
+const mailSendTimeout = time.Second * 30
+
+// sendMail delivers msg in the background so that slow mail servers don't
+// hold up the response, and so response times don't reveal whether an email
+// was sent at all.
+func (cfg *Config) sendMail(msg mailer.Message) {
+	cfg.mailInFlight.Add(1)
+	go func() {
+		defer cfg.mailInFlight.Done()
+
+		ctx, cancel := context.WithTimeout(context.Background(), mailSendTimeout)
+		defer cancel()
+
+		if err := cfg.mailer.Send(ctx, msg); err != nil {
+			log.Printf("Error sending %q email: %v", msg.Subject, err)
+		}
+	}()
+}
+
+// WaitForMail waits for the email being sent in the background, or until
+// ctx is done. The server calls it on shutdown once no more requests come
+// in.
+func (cfg *Config) WaitForMail(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		cfg.mailInFlight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// appLink builds a link to a frontend page carrying a token in its query.
+func (cfg *Config) appLink(path, token string) string {
+	return cfg.appURL + path + "?token=" + url.QueryEscape(token)
+}
+
+func passwordResetEmail(to, link string, lifetime time.Duration) mailer.Message {
+	return mailer.Message{
+		To:      to,
+		Subject: "Reset your potom password",
+		Body: fmt.Sprintf("Someone asked to reset the password for your potom account.\n\n"+
+			"Follow this link within %s to choose a new password:\n%s\n\n"+
+			"If it wasn't you, you can ignore this email.", lifetime, link),
+	}
+}
+
+func emailVerificationEmail(to, link string, lifetime time.Duration) mailer.Message {
+	return mailer.Message{
+		To:      to,
+		Subject: "Confirm your email for potom",
+		Body: fmt.Sprintf("Please confirm that this is your email address by following this link within %s:\n%s\n\n"+
+			"If you didn't sign up for potom, you can ignore this email.", lifetime, link),
+	}
+}
+
+func emailChangedEmail(to, newEmail string) mailer.Message {
+	return mailer.Message{
+		To:      to,
+		Subject: "Your potom email was changed",
+		Body: fmt.Sprintf("The email address of your potom account was changed to %s.\n\n"+
+			"If it wasn't you, contact support right away.", newEmail),
+	}
+}
+
+func groupInviteEmail(to, groupName, link string) mailer.Message {
+	return mailer.Message{
+		To:      to,
+		Subject: fmt.Sprintf("You've been invited to %s on potom", groupName),
+		Body: fmt.Sprintf("You've been invited to join the group %q on potom.\n\n"+
+			"Follow this link to accept or decline the invitation:\n%s", groupName, link),
+	}
+}
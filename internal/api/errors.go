@@ -0,0 +1,142 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/lib/pq"
+	"github.com/potom-dev/backend/internal/validation"
+)
+
+// errorCode is an entry of the error catalog. code is stable, so clients can
+// branch on it, unlike the detail of an error which may be reworded at any
+// time.
+type errorCode struct {
+	status int
+	code   string
+	title  string
+}
+
+var (
+	codeInvalidRequest        = errorCode{http.StatusBadRequest, "invalid_request", "The request is malformed"}
+	codeUnauthenticated       = errorCode{http.StatusUnauthorized, "unauthenticated", "Authentication is required"}
+	codeInvalidCredentials    = errorCode{http.StatusUnauthorized, "invalid_credentials", "The credentials are incorrect"}
+	codeInvalidToken          = errorCode{http.StatusUnauthorized, "invalid_token", "The token is invalid or has expired"}
+	codeForbidden             = errorCode{http.StatusForbidden, "forbidden", "The caller isn't allowed to do this"}
+	codeInsufficientScope     = errorCode{http.StatusForbidden, "insufficient_scope", "The API key wasn't granted the scope this needs"}
+	codeEmailNotVerified      = errorCode{http.StatusForbidden, "email_not_verified", "The email address must be verified first"}
+	codePasswordResetRequired = errorCode{http.StatusForbidden, "password_reset_required", "The password must be reset first"}
+	codeAccountDisabled       = errorCode{http.StatusForbidden, "account_disabled", "The account is disabled"}
+	codeAccountDeleted        = errorCode{http.StatusForbidden, "account_deleted", "The account has been deleted"}
+	codeNotFound              = errorCode{http.StatusNotFound, "not_found", "The resource doesn't exist"}
+	codeMethodNotAllowed      = errorCode{http.StatusMethodNotAllowed, "method_not_allowed", "The method isn't allowed here"}
+	codeConflict              = errorCode{http.StatusConflict, "conflict", "The request conflicts with the current state"}
+	codeGone                  = errorCode{http.StatusGone, "gone", "The resource is no longer available"}
+	codePayloadTooLarge       = errorCode{http.StatusRequestEntityTooLarge, "payload_too_large", "The request body is too large"}
+	codeValidationFailed      = errorCode{http.StatusUnprocessableEntity, "validation_failed", "The request parameters are invalid"}
+	codeRateLimited           = errorCode{http.StatusTooManyRequests, "rate_limited", "Too many requests"}
+	codeInternal              = errorCode{http.StatusInternalServerError, "internal_error", "Something went wrong on our side"}
+)
+
+// apiError is an error handlers return to respond with a problem. detail is
+// sent to the client, cause is only logged.
+type apiError struct {
+	errorCode
+	detail string
+	fields validation.Errors
+	cause  error
+}
+
+func newError(code errorCode, detail string, cause error) *apiError {
+	return &apiError{errorCode: code, detail: detail, cause: cause}
+}
+
+func (e *apiError) Error() string {
+	if e.cause == nil {
+		return e.detail
+	}
+	return e.detail + ": " + e.cause.Error()
+}
+
+func (e *apiError) Unwrap() error {
+	return e.cause
+}
+
+// Problem is an RFC 7807 problem details object, extended with the error
+// code, the request ID and, when parameters were rejected, the invalid
+// fields.
+type Problem struct {
+	Type      string                  `json:"type"`
+	Title     string                  `json:"title"`
+	Status    int                     `json:"status"`
+	Detail    string                  `json:"detail,omitempty"`
+	Instance  string                  `json:"instance"`
+	Code      string                  `json:"code"`
+	RequestId string                  `json:"request_id,omitempty"`
+	Fields    []validation.FieldError `json:"fields,omitempty"`
+}
+
+// handlerFunc is an API handler. Rather than writing error responses itself,
+// it returns an error, and ServeHTTP responds with the matching problem.
+type handlerFunc func(http.ResponseWriter, *http.Request) error
+
+func (h handlerFunc) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := h(w, r); err != nil {
+		respondWithProblem(w, r, err)
+	}
+}
+
+// respondWithProblem responds with the problem err describes. Errors that
+// aren't an apiError are internal errors, unless they come from a query that
+// found no row or broke a unique constraint.
+func respondWithProblem(w http.ResponseWriter, r *http.Request, err error) {
+	var e *apiError
+	if !errors.As(err, &e) {
+		e = &apiError{errorCode: codeInternal, cause: err}
+	}
+
+	code := e.errorCode
+	if code == codeInternal {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			code = codeNotFound
+		case isUniqueViolation(err):
+			code = codeConflict
+		}
+	}
+
+	requestID := RequestIDFromContext(r.Context())
+	if code.status > 499 {
+		log.Printf("[%s] Responding with %d error: %s", requestID, code.status, e.detail)
+	}
+	if e.cause != nil {
+		log.Printf("[%s] Error: %v", requestID, e.cause)
+	}
+
+	dat, err := json.Marshal(Problem{
+		Type:      "about:blank",
+		Title:     code.title,
+		Status:    code.status,
+		Detail:    e.detail,
+		Instance:  r.URL.Path,
+		Code:      code.code,
+		RequestId: requestID,
+		Fields:    e.fields,
+	})
+	if err != nil {
+		log.Printf("[%s] Error marshalling problem: %s", requestID, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(code.status)
+	w.Write(dat)
+}
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
@@ -0,0 +1,229 @@
+package api
+
+import (
+	"archive/zip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AccountExport is everything stored about a user, apart from secrets such
+// as password hashes and token hashes.
+type AccountExport struct {
+	ExportedAt  time.Time                 `json:"exported_at"`
+	User        User                      `json:"user"`
+	Identities  []Identity                `json:"identities"`
+	Sessions    []ExportedSession         `json:"sessions"`
+	ApiKeys     []ApiKey                  `json:"api_keys"`
+	Groups      []ExportedGroupMembership `json:"groups"`
+	Invites     []GroupInvite             `json:"invites"`
+	Mfa         ExportedMfa               `json:"mfa"`
+	AuditEvents []ExportedAuditEvent      `json:"audit_events"`
+}
+
+// ExportedSession is one refresh token of a session, including rotated and
+// revoked ones.
+type ExportedSession struct {
+	Session
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+type ExportedGroupMembership struct {
+	GroupId   uuid.UUID `json:"group_id"`
+	GroupName string    `json:"group_name"`
+	Role      string    `json:"role"`
+	JoinedAt  time.Time `json:"joined_at"`
+}
+
+type ExportedMfa struct {
+	TotpEnabled     bool       `json:"totp_enabled"`
+	TotpConfirmedAt *time.Time `json:"totp_confirmed_at,omitempty"`
+}
+
+type ExportedAuditEvent struct {
+	EventType string    `json:"event_type"`
+	IpAddress string    `json:"ip_address,omitempty"`
+	Detail    string    `json:"detail,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// handlerExportMe godoc
+//
+//	@Router		/users/me/export [get]
+//	@Summary	export everything stored about the caller
+//	@Description	Returns a JSON document, or with format=zip a ZIP archive with one JSON file per section.
+//	@Tags		users
+//	@Produce	json
+//	@Produce	application/zip
+//	@Param		Authorization	header	string	true	"Bearer token"
+//	@Param		format	query		string	false	"json (default) or zip"
+//	@Success	200		{object}	AccountExport
+//	@Failure	400		{object}	Problem
+//	@Failure	401		{object}	Problem
+//	@Failure	500		{object}	Problem
+//	@Security	BearerAuth
+func (cfg *Config) handlerExportMe(w http.ResponseWriter, r *http.Request) error {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "zip" {
+		return newError(codeInvalidRequest, "Invalid format", nil)
+	}
+
+	export, err := cfg.exportAccount(r.Context(), UserIDFromContext(r.Context()))
+	if err != nil {
+		return newError(codeInternal, "Couldn't export account", err)
+	}
+
+	if format == "json" {
+		w.Header().Set("Content-Disposition", `attachment; filename="potom-export.json"`)
+		respondWithJSON(w, http.StatusOK, export)
+		return nil
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="potom-export.zip"`)
+	w.WriteHeader(http.StatusOK)
+	if err := writeExportZip(w, export); err != nil {
+		// The status is already sent, all we can do is cut the archive short.
+		log.Printf("Error writing export for user %s: %v", export.User.Id, err)
+	}
+
+	return nil
+}
+
+func (cfg *Config) exportAccount(ctx context.Context, userID uuid.UUID) (AccountExport, error) {
+	export := AccountExport{
+		ExportedAt:  time.Now(),
+		Identities:  []Identity{},
+		Sessions:    []ExportedSession{},
+		ApiKeys:     []ApiKey{},
+		Groups:      []ExportedGroupMembership{},
+		Invites:     []GroupInvite{},
+		AuditEvents: []ExportedAuditEvent{},
+	}
+
+	user, err := cfg.db.GetUserById(ctx, userID)
+	if err != nil {
+		return export, err
+	}
+	export.User = userFromDB(user)
+
+	identities, err := cfg.db.GetUserIdentities(ctx, userID)
+	if err != nil {
+		return export, err
+	}
+	for _, identity := range identities {
+		export.Identities = append(export.Identities, identityFromDB(identity))
+	}
+
+	tokens, err := cfg.db.GetRefreshTokensForUser(ctx, userID)
+	if err != nil {
+		return export, err
+	}
+	for _, token := range tokens {
+		session := ExportedSession{Session: sessionFromDB(token), CreatedAt: token.CreatedAt}
+		if token.RevokedAt.Valid {
+			session.RevokedAt = &token.RevokedAt.Time
+		}
+		export.Sessions = append(export.Sessions, session)
+	}
+
+	keys, err := cfg.db.GetApiKeys(ctx, userID)
+	if err != nil {
+		return export, err
+	}
+	for _, key := range keys {
+		export.ApiKeys = append(export.ApiKeys, apiKeyFromDB(key))
+	}
+
+	memberships, err := cfg.db.GetGroupMembershipsForUser(ctx, userID)
+	if err != nil {
+		return export, err
+	}
+	for _, membership := range memberships {
+		export.Groups = append(export.Groups, ExportedGroupMembership{
+			GroupId:   membership.GroupID,
+			GroupName: membership.Name,
+			Role:      membership.Role,
+			JoinedAt:  membership.CreatedAt,
+		})
+	}
+
+	invites, err := cfg.db.GetGroupInvitesCreatedBy(ctx, userID)
+	if err != nil {
+		return export, err
+	}
+	for _, invite := range invites {
+		export.Invites = append(export.Invites, groupInviteFromDB(invite))
+	}
+
+	totp, err := cfg.db.GetUserTotp(ctx, userID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return export, err
+	}
+	if err == nil && totp.ConfirmedAt.Valid {
+		export.Mfa = ExportedMfa{TotpEnabled: true, TotpConfirmedAt: &totp.ConfirmedAt.Time}
+	}
+
+	events, err := cfg.db.GetAuditEventsForUser(ctx, uuid.NullUUID{UUID: userID, Valid: true})
+	if err != nil {
+		return export, err
+	}
+	for _, event := range events {
+		export.AuditEvents = append(export.AuditEvents, ExportedAuditEvent{
+			EventType: event.EventType,
+			IpAddress: event.IpAddress.String,
+			Detail:    event.Detail.String,
+			CreatedAt: event.CreatedAt,
+		})
+	}
+
+	return export, nil
+}
+
+// writeExportZip writes export as a ZIP archive with a JSON file per section.
+func writeExportZip(w io.Writer, export AccountExport) error {
+	files := []struct {
+		name string
+		data any
+	}{
+		{"user.json", export.User},
+		{"identities.json", export.Identities},
+		{"sessions.json", export.Sessions},
+		{"api_keys.json", export.ApiKeys},
+		{"groups.json", export.Groups},
+		{"invites.json", export.Invites},
+		{"mfa.json", export.Mfa},
+		{"audit_events.json", export.AuditEvents},
+	}
+
+	zw := zip.NewWriter(w)
+	for _, file := range files {
+		fw, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     file.name,
+			Method:   zip.Deflate,
+			Modified: export.ExportedAt,
+		})
+		if err != nil {
+			return err
+		}
+
+		enc := json.NewEncoder(fw)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(file.data); err != nil {
+			return err
+		}
+	}
+
+	return zw.Close()
+}
@@ -0,0 +1,390 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/potom-dev/backend/internal/database"
+	"github.com/potom-dev/backend/internal/pagination"
+	"github.com/potom-dev/backend/internal/validation"
+)
+
+const (
+	groupRoleOwner  = "owner"
+	groupRoleAdmin  = "admin"
+	groupRoleMember = "member"
+)
+
+// groupRoleRank orders group roles by privilege. Unknown roles rank lowest.
+func groupRoleRank(role string) int {
+	switch role {
+	case groupRoleOwner:
+		return 3
+	case groupRoleAdmin:
+		return 2
+	case groupRoleMember:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func groupRoleAtLeast(role, minimum string) bool {
+	return groupRoleRank(role) >= groupRoleRank(minimum)
+}
+
+type AddGroupMemberParams struct {
+	UserId uuid.UUID `json:"user_id"`
+	Role   string    `json:"role,omitempty"`
+}
+
+func (p AddGroupMemberParams) Validate(v *validation.Validator) {
+	v.Check(p.UserId != uuid.Nil, "user_id", "is required")
+	if p.Role != "" {
+		v.OneOf("role", p.Role, groupRoleOwner, groupRoleAdmin, groupRoleMember)
+	}
+}
+
+type UpdateGroupMemberParams struct {
+	Role string `json:"role"`
+}
+
+func (p UpdateGroupMemberParams) Validate(v *validation.Validator) {
+	v.OneOf("role", p.Role, groupRoleOwner, groupRoleAdmin, groupRoleMember)
+}
+
+type GroupMember struct {
+	UserId   uuid.UUID `json:"user_id"`
+	Email    string    `json:"email,omitempty"`
+	Role     string    `json:"role"`
+	JoinedAt time.Time `json:"joined_at"`
+}
+
+// handlerGetGroupMembers godoc
+//
+//	@Router		/groups/{groupId}/members [get]
+//	@Summary	list group members
+//	@Tags		groups
+//	@Accept		json
+//	@Produce	json
+//	@Param		Authorization	header	string	true	"Bearer token"
+//	@Param		groupId	path		string	true	"Group ID"
+//	@Param		limit	query		int		false	"Page size, 1 to 200"	default(50)
+//	@Param		cursor	query		string	false	"next_cursor of the previous page"
+//	@Success	200		{object}	pagination.Page[GroupMember]
+//	@Failure	400		{object}	Problem
+//	@Failure	401		{object}	Problem
+//	@Failure	404		{object}	Problem
+//	@Failure	500		{object}	Problem
+//	@Security	BearerAuth
+func (cfg *Config) handlerGetGroupMembers(w http.ResponseWriter, r *http.Request) error {
+	group, _, err := cfg.loadGroupMembership(r)
+	if err != nil {
+		return err
+	}
+
+	page, err := parsePage(r, "created_at")
+	if err != nil {
+		return err
+	}
+
+	members, err := cfg.db.ListGroupMembers(r.Context(), database.ListGroupMembersParams{
+		GroupID:        group.ID,
+		AfterCreatedAt: page.AfterTime(),
+		AfterID:        page.AfterID(),
+		Limit:          page.FetchLimit(),
+	})
+	if err != nil {
+		return newError(codeInternal, "Couldn't get group members", err)
+	}
+
+	cursor := func(member database.ListGroupMembersRow) pagination.Cursor {
+		return pagination.Cursor{Time: &member.CreatedAt, ID: member.UserID}
+	}
+	item := func(member database.ListGroupMembersRow) GroupMember {
+		return GroupMember{
+			UserId:   member.UserID,
+			Email:    member.Email,
+			Role:     member.Role,
+			JoinedAt: member.CreatedAt,
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, pagination.NewPage(members, page, cursor, item))
+	return nil
+}
+
+// handlerAddGroupMember godoc
+//
+//	@Router		/groups/{groupId}/members [post]
+//	@Summary	add a group member
+//	@Tags		groups
+//	@Accept		json
+//	@Produce	json
+//	@Param		Authorization	header	string	true	"Bearer token"
+//	@Param		groupId	path		string					true	"Group ID"
+//	@Param		body	body		AddGroupMemberParams	true	"Member to add"
+//	@Success	201		{object}	GroupMember
+//	@Failure	400		{object}	Problem
+//	@Failure	401		{object}	Problem
+//	@Failure	403		{object}	Problem
+//	@Failure	404		{object}	Problem
+//	@Failure	409		{object}	Problem
+//	@Failure	422		{object}	Problem
+//	@Failure	500		{object}	Problem
+//	@Security	BearerAuth
+func (cfg *Config) handlerAddGroupMember(w http.ResponseWriter, r *http.Request) error {
+	group, membership, err := cfg.loadGroupMembership(r)
+	if err != nil {
+		return err
+	}
+
+	params := AddGroupMemberParams{}
+	if err := decodeParams(w, r, &params); err != nil {
+		return err
+	}
+
+	if params.Role == "" {
+		params.Role = groupRoleMember
+	}
+
+	if !groupRoleAtLeast(membership.Role, groupRoleAdmin) {
+		return newError(codeForbidden, "Only group owners and admins can add members", nil)
+	}
+	if params.Role != groupRoleMember && membership.Role != groupRoleOwner {
+		return newError(codeForbidden, "Only group owners can grant elevated roles", nil)
+	}
+
+	user, err := cfg.db.GetUserById(r.Context(), params.UserId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return newError(codeNotFound, "User not found", nil)
+	}
+	if err != nil {
+		return newError(codeInternal, "Couldn't get user", err)
+	}
+
+	if cfg.requireVerifiedEmailForGroups && !user.EmailVerifiedAt.Valid {
+		return newError(codeConflict, "User has not verified their email", nil)
+	}
+
+	_, err = cfg.db.GetGroupMember(r.Context(), database.GetGroupMemberParams{
+		GroupID: group.ID,
+		UserID:  user.ID,
+	})
+	if err == nil {
+		return newError(codeConflict, "User is already a member", nil)
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return newError(codeInternal, "Couldn't get group membership", err)
+	}
+
+	member, err := cfg.db.AddGroupMember(r.Context(), database.AddGroupMemberParams{
+		GroupID: group.ID,
+		UserID:  user.ID,
+		Role:    params.Role,
+	})
+	if err != nil {
+		return newError(codeInternal, "Couldn't add group member", err)
+	}
+
+	respondWithJSON(w, http.StatusCreated, GroupMember{
+		UserId:   member.UserID,
+		Email:    user.Email,
+		Role:     member.Role,
+		JoinedAt: member.CreatedAt,
+	})
+	return nil
+}
+
+// handlerUpdateGroupMember godoc
+//
+//	@Router		/groups/{groupId}/members/{userId} [patch]
+//	@Summary	change a group member's role
+//	@Tags		groups
+//	@Accept		json
+//	@Produce	json
+//	@Param		Authorization	header	string	true	"Bearer token"
+//	@Param		groupId	path		string					true	"Group ID"
+//	@Param		userId	path		string					true	"User ID"
+//	@Param		body	body		UpdateGroupMemberParams	true	"New role"
+//	@Success	200		{object}	GroupMember
+//	@Failure	400		{object}	Problem
+//	@Failure	401		{object}	Problem
+//	@Failure	403		{object}	Problem
+//	@Failure	404		{object}	Problem
+//	@Failure	409		{object}	Problem
+//	@Failure	422		{object}	Problem
+//	@Failure	500		{object}	Problem
+//	@Security	BearerAuth
+func (cfg *Config) handlerUpdateGroupMember(w http.ResponseWriter, r *http.Request) error {
+	group, membership, err := cfg.loadGroupMembership(r)
+	if err != nil {
+		return err
+	}
+
+	target, err := cfg.loadTargetGroupMember(r, group.ID)
+	if err != nil {
+		return err
+	}
+
+	params := UpdateGroupMemberParams{}
+	if err := decodeParams(w, r, &params); err != nil {
+		return err
+	}
+
+	if membership.Role != groupRoleOwner {
+		return newError(codeForbidden, "Only group owners can change roles", nil)
+	}
+
+	var member database.GroupMember
+	var user database.User
+	err = cfg.withTx(r.Context(), func(q *database.Queries) error {
+		// The member may have left or been removed since it was loaded.
+		var err error
+		target, err = q.GetGroupMember(r.Context(), database.GetGroupMemberParams{
+			GroupID: group.ID,
+			UserID:  target.UserID,
+		})
+		if err != nil {
+			return err
+		}
+
+		if params.Role != groupRoleOwner {
+			if err := ensureAnotherOwner(r.Context(), q, group.ID, target.UserID); err != nil {
+				return err
+			}
+		}
+
+		member, err = q.UpdateGroupMemberRole(r.Context(), database.UpdateGroupMemberRoleParams{
+			GroupID: group.ID,
+			UserID:  target.UserID,
+			Role:    params.Role,
+		})
+		if err != nil {
+			return err
+		}
+
+		user, err = q.GetUserById(r.Context(), member.UserID)
+		return err
+	})
+	if errors.Is(err, errLastGroupOwner) {
+		return newError(codeConflict, "A group must keep at least one owner", nil)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return newError(codeNotFound, "Member not found", nil)
+	}
+	if err != nil {
+		return newError(codeInternal, "Couldn't update group member", err)
+	}
+
+	respondWithJSON(w, http.StatusOK, GroupMember{
+		UserId:   member.UserID,
+		Email:    user.Email,
+		Role:     member.Role,
+		JoinedAt: member.CreatedAt,
+	})
+	return nil
+}
+
+// handlerRemoveGroupMember godoc
+//
+//	@Router		/groups/{groupId}/members/{userId} [delete]
+//	@Summary	remove a group member or leave a group
+//	@Tags		groups
+//	@Accept		json
+//	@Produce	json
+//	@Param		Authorization	header	string	true	"Bearer token"
+//	@Param		groupId	path	string	true	"Group ID"
+//	@Param		userId	path	string	true	"User ID"
+//	@Success	204		"No Content"
+//	@Failure	400		{object}	Problem
+//	@Failure	401		{object}	Problem
+//	@Failure	403		{object}	Problem
+//	@Failure	404		{object}	Problem
+//	@Failure	409		{object}	Problem
+//	@Failure	500		{object}	Problem
+//	@Security	BearerAuth
+func (cfg *Config) handlerRemoveGroupMember(w http.ResponseWriter, r *http.Request) error {
+	group, membership, err := cfg.loadGroupMembership(r)
+	if err != nil {
+		return err
+	}
+
+	target, err := cfg.loadTargetGroupMember(r, group.ID)
+	if err != nil {
+		return err
+	}
+
+	leaving := target.UserID == membership.UserID
+	if !leaving {
+		canRemove := membership.Role == groupRoleOwner ||
+			(groupRoleAtLeast(membership.Role, groupRoleAdmin) && groupRoleRank(target.Role) < groupRoleRank(membership.Role))
+		if !canRemove {
+			return newError(codeForbidden, "Not allowed to remove this member", nil)
+		}
+	}
+
+	err = cfg.withTx(r.Context(), func(q *database.Queries) error {
+		if err := ensureAnotherOwner(r.Context(), q, group.ID, target.UserID); err != nil {
+			return err
+		}
+
+		return q.RemoveGroupMember(r.Context(), database.RemoveGroupMemberParams{
+			GroupID: group.ID,
+			UserID:  target.UserID,
+		})
+	})
+	if errors.Is(err, errLastGroupOwner) {
+		return newError(codeConflict, "A group must keep at least one owner", nil)
+	}
+	if err != nil {
+		return newError(codeInternal, "Couldn't remove group member", err)
+	}
+
+	respondWithJSON(w, http.StatusNoContent, nil)
+	return nil
+}
+
+// loadTargetGroupMember fetches the membership named by the userId path value.
+func (cfg *Config) loadTargetGroupMember(r *http.Request, groupID uuid.UUID) (database.GroupMember, error) {
+	userID, err := uuid.Parse(r.PathValue("userId"))
+	if err != nil {
+		return database.GroupMember{}, newError(codeInvalidRequest, "Invalid user ID", err)
+	}
+
+	member, err := cfg.db.GetGroupMember(r.Context(), database.GetGroupMemberParams{
+		GroupID: groupID,
+		UserID:  userID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.GroupMember{}, newError(codeNotFound, "Member not found", nil)
+	}
+	if err != nil {
+		return database.GroupMember{}, newError(codeInternal, "Couldn't get group member", err)
+	}
+
+	return member, nil
+}
+
+var errLastGroupOwner = errors.New("group must keep at least one owner")
+
+// ensureAnotherOwner fails with errLastGroupOwner if userID is the only owner
+// of the group, who mustn't be demoted or removed. It must run in the same
+// transaction as the change.
+func ensureAnotherOwner(ctx context.Context, q *database.Queries, groupID, userID uuid.UUID) error {
+	// The owner rows stay locked until the transaction ends, so two owners
+	// demoting or removing each other at once can't both pass the check.
+	owners, err := q.LockGroupOwners(ctx, groupID)
+	if err != nil {
+		return err
+	}
+	if slices.Equal(owners, []uuid.UUID{userID}) {
+		return errLastGroupOwner
+	}
+	return nil
+}
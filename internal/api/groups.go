@@ -1,26 +1,59 @@
 package api
 
 import (
-	"encoding/json"
+	"database/sql"
+	"errors"
 	"net/http"
 	"time"
 
 	"github.com/google/uuid"
-	"github.com/potom-dev/backend/internal/auth"
 	"github.com/potom-dev/backend/internal/database"
+	"github.com/potom-dev/backend/internal/pagination"
+	"github.com/potom-dev/backend/internal/validation"
 )
 
+// maxGroupNameLength is as long as the groups table allows.
+const maxGroupNameLength = 255
+
 type CreateGroupParams struct {
 	Name string `json:"name"`
 }
 
+func (p CreateGroupParams) Validate(v *validation.Validator) {
+	validateGroupName(v, p.Name)
+}
+
+type UpdateGroupParams struct {
+	Name string `json:"name"`
+}
+
+func (p UpdateGroupParams) Validate(v *validation.Validator) {
+	validateGroupName(v, p.Name)
+}
+
+func validateGroupName(v *validation.Validator, name string) {
+	v.Required("name", name)
+	v.MaxLength("name", name, maxGroupNameLength)
+}
+
 type Group struct {
 	Id        uuid.UUID `json:"id"`
 	Name      string    `json:"name"`
+	AuthorId  uuid.UUID `json:"author_id"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
+func groupFromDB(group database.Group) Group {
+	return Group{
+		Id:        group.ID,
+		Name:      group.Name,
+		AuthorId:  group.AuthorID,
+		CreatedAt: group.CreatedAt,
+		UpdatedAt: group.UpdatedAt,
+	}
+}
+
 // handlerCreateGroup godoc
 //
 //	@Router		/groups [post]
@@ -31,43 +64,261 @@ type Group struct {
 //	@Param		Authorization	header	string	true	"Bearer token"
 //	@Param		body	body		CreateGroupParams	true	"Group creation parameters"
 //	@Success	201		{object}	Group
-//	@Failure	401		{object}	ErrorResponse
-//	@Failure	500		{object}	ErrorResponse
+//	@Failure	400		{object}	Problem
+//	@Failure	401		{object}	Problem
+//	@Failure	403		{object}	Problem
+//	@Failure	422		{object}	Problem
+//	@Failure	500		{object}	Problem
 //	@Security	BearerAuth
-func (cfg *Config) handlerCreateGroup(w http.ResponseWriter, r *http.Request) {
-	decoder := json.NewDecoder(r.Body)
+func (cfg *Config) handlerCreateGroup(w http.ResponseWriter, r *http.Request) error {
+	if err := cfg.requireVerifiedEmail(r); err != nil {
+		return err
+	}
+
 	params := CreateGroupParams{}
+	if err := decodeParams(w, r, &params); err != nil {
+		return err
+	}
+
+	userID := UserIDFromContext(r.Context())
+
+	var group database.Group
+	err := cfg.withTx(r.Context(), func(q *database.Queries) error {
+		var err error
+		group, err = q.CreateGroup(r.Context(), database.CreateGroupParams{
+			Name:     params.Name,
+			AuthorID: userID,
+		})
+		if err != nil {
+			return err
+		}
 
-	if err := decoder.Decode(&params); err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters", err)
-		return
+		_, err = q.AddGroupMember(r.Context(), database.AddGroupMemberParams{
+			GroupID: group.ID,
+			UserID:  userID,
+			Role:    groupRoleOwner,
+		})
+		return err
+	})
+	if err != nil {
+		return newError(codeInternal, "Couldn't create group", err)
 	}
 
-	token, err := auth.GetBearerToken(r.Header)
+	respondWithJSON(w, http.StatusCreated, groupFromDB(group))
+	return nil
+}
+
+// handlerGetGroups godoc
+//
+//	@Router		/groups [get]
+//	@Summary	list the caller's groups
+//	@Tags		groups
+//	@Accept		json
+//	@Produce	json
+//	@Param		Authorization	header	string	true	"Bearer token"
+//	@Param		limit		query	int		false	"Page size, 1 to 200"	default(50)
+//	@Param		cursor		query	string	false	"next_cursor of the previous page"
+//	@Param		sort		query	string	false	"Sort order"	Enums(created_at, -created_at, name, -name)	default(created_at)
+//	@Param		name_prefix	query	string	false	"Only groups whose name starts with this, ignoring case"
+//	@Success	200	{object}	pagination.Page[Group]
+//	@Failure	400	{object}	Problem
+//	@Failure	401	{object}	Problem
+//	@Failure	500	{object}	Problem
+//	@Security	BearerAuth
+func (cfg *Config) handlerGetGroups(w http.ResponseWriter, r *http.Request) error {
+	page, err := parsePage(r, "created_at", "-created_at", "name", "-name")
+	if err != nil {
+		return err
+	}
+
+	userID := UserIDFromContext(r.Context())
+	namePrefix := pagination.PrefixFilter(r.URL.Query(), "name_prefix")
+
+	cursor := func(group database.Group) pagination.Cursor {
+		return pagination.Cursor{Time: &group.CreatedAt, ID: group.ID}
+	}
+
+	var groups []database.Group
+	switch page.Sort {
+	case "created_at":
+		groups, err = cfg.db.ListGroupsForUserByCreatedAt(r.Context(), database.ListGroupsForUserByCreatedAtParams{
+			UserID:         userID,
+			NamePrefix:     namePrefix,
+			AfterCreatedAt: page.AfterTime(),
+			AfterID:        page.AfterID(),
+			Limit:          page.FetchLimit(),
+		})
+	case "-created_at":
+		groups, err = cfg.db.ListGroupsForUserByCreatedAtDesc(r.Context(), database.ListGroupsForUserByCreatedAtDescParams{
+			UserID:         userID,
+			NamePrefix:     namePrefix,
+			AfterCreatedAt: page.AfterTime(),
+			AfterID:        page.AfterID(),
+			Limit:          page.FetchLimit(),
+		})
+	case "name":
+		groups, err = cfg.db.ListGroupsForUserByName(r.Context(), database.ListGroupsForUserByNameParams{
+			UserID:     userID,
+			NamePrefix: namePrefix,
+			AfterName:  page.AfterValue(),
+			AfterID:    page.AfterID(),
+			Limit:      page.FetchLimit(),
+		})
+	case "-name":
+		groups, err = cfg.db.ListGroupsForUserByNameDesc(r.Context(), database.ListGroupsForUserByNameDescParams{
+			UserID:     userID,
+			NamePrefix: namePrefix,
+			AfterName:  page.AfterValue(),
+			AfterID:    page.AfterID(),
+			Limit:      page.FetchLimit(),
+		})
+	}
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't get bearer token", err)
-		return
+		return newError(codeInternal, "Couldn't get groups", err)
+	}
+
+	if page.Sort == "name" || page.Sort == "-name" {
+		cursor = func(group database.Group) pagination.Cursor {
+			return pagination.Cursor{Value: group.Name, ID: group.ID}
+		}
 	}
 
-	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	respondWithJSON(w, http.StatusOK, pagination.NewPage(groups, page, cursor, groupFromDB))
+	return nil
+}
+
+// handlerGetGroup godoc
+//
+//	@Router		/groups/{groupId} [get]
+//	@Summary	get group by id
+//	@Tags		groups
+//	@Accept		json
+//	@Produce	json
+//	@Param		Authorization	header	string	true	"Bearer token"
+//	@Param		groupId	path		string	true	"Group ID"
+//	@Success	200		{object}	Group
+//	@Failure	400		{object}	Problem
+//	@Failure	401		{object}	Problem
+//	@Failure	404		{object}	Problem
+//	@Failure	500		{object}	Problem
+//	@Security	BearerAuth
+func (cfg *Config) handlerGetGroup(w http.ResponseWriter, r *http.Request) error {
+	group, _, err := cfg.loadGroupMembership(r)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
-		return
+		return err
 	}
 
-	group, err := cfg.db.CreateGroup(r.Context(), database.CreateGroupParams{
-		Name:     params.Name,
-		AuthorID: userID,
+	respondWithJSON(w, http.StatusOK, groupFromDB(group))
+	return nil
+}
+
+// handlerUpdateGroup godoc
+//
+//	@Router		/groups/{groupId} [patch]
+//	@Summary	rename a group
+//	@Tags		groups
+//	@Accept		json
+//	@Produce	json
+//	@Param		Authorization	header	string	true	"Bearer token"
+//	@Param		groupId	path		string				true	"Group ID"
+//	@Param		body	body		UpdateGroupParams	true	"Group update parameters"
+//	@Success	200		{object}	Group
+//	@Failure	400		{object}	Problem
+//	@Failure	401		{object}	Problem
+//	@Failure	403		{object}	Problem
+//	@Failure	404		{object}	Problem
+//	@Failure	422		{object}	Problem
+//	@Failure	500		{object}	Problem
+//	@Security	BearerAuth
+func (cfg *Config) handlerUpdateGroup(w http.ResponseWriter, r *http.Request) error {
+	group, membership, err := cfg.loadGroupMembership(r)
+	if err != nil {
+		return err
+	}
+
+	if !groupRoleAtLeast(membership.Role, groupRoleAdmin) {
+		return newError(codeForbidden, "Only group owners and admins can modify the group", nil)
+	}
+
+	params := UpdateGroupParams{}
+	if err := decodeParams(w, r, &params); err != nil {
+		return err
+	}
+
+	group, err = cfg.db.UpdateGroupName(r.Context(), database.UpdateGroupNameParams{
+		ID:   group.ID,
+		Name: params.Name,
 	})
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't create group", err)
-		return
+		return newError(codeInternal, "Couldn't update group", err)
 	}
 
-	respondWithJSON(w, http.StatusCreated, Group{
-		Id:        group.ID,
-		Name:      group.Name,
-		CreatedAt: group.CreatedAt,
-		UpdatedAt: group.UpdatedAt,
+	respondWithJSON(w, http.StatusOK, groupFromDB(group))
+	return nil
+}
+
+// handlerDeleteGroup godoc
+//
+//	@Router		/groups/{groupId} [delete]
+//	@Summary	delete a group
+//	@Tags		groups
+//	@Accept		json
+//	@Produce	json
+//	@Param		Authorization	header	string	true	"Bearer token"
+//	@Param		groupId	path	string	true	"Group ID"
+//	@Success	204		"No Content"
+//	@Failure	400		{object}	Problem
+//	@Failure	401		{object}	Problem
+//	@Failure	403		{object}	Problem
+//	@Failure	404		{object}	Problem
+//	@Failure	500		{object}	Problem
+//	@Security	BearerAuth
+func (cfg *Config) handlerDeleteGroup(w http.ResponseWriter, r *http.Request) error {
+	group, membership, err := cfg.loadGroupMembership(r)
+	if err != nil {
+		return err
+	}
+
+	if !groupRoleAtLeast(membership.Role, groupRoleOwner) {
+		return newError(codeForbidden, "Only group owners can delete the group", nil)
+	}
+
+	err = cfg.db.DeleteGroup(r.Context(), group.ID)
+	if err != nil {
+		return newError(codeInternal, "Couldn't delete group", err)
+	}
+
+	respondWithJSON(w, http.StatusNoContent, nil)
+	return nil
+}
+
+// loadGroupMembership fetches the group named by the groupId path value
+// together with the caller's membership, or returns an error if either can't
+// be found. Groups the caller doesn't belong to are reported as not found.
+func (cfg *Config) loadGroupMembership(r *http.Request) (database.Group, database.GroupMember, error) {
+	groupID, err := uuid.Parse(r.PathValue("groupId"))
+	if err != nil {
+		return database.Group{}, database.GroupMember{}, newError(codeInvalidRequest, "Invalid group ID", err)
+	}
+
+	group, err := cfg.db.GetGroupById(r.Context(), groupID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.Group{}, database.GroupMember{}, newError(codeNotFound, "Group not found", nil)
+	}
+	if err != nil {
+		return database.Group{}, database.GroupMember{}, newError(codeInternal, "Couldn't get group", err)
+	}
+
+	membership, err := cfg.db.GetGroupMember(r.Context(), database.GetGroupMemberParams{
+		GroupID: group.ID,
+		UserID:  UserIDFromContext(r.Context()),
 	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.Group{}, database.GroupMember{}, newError(codeNotFound, "Group not found", nil)
+	}
+	if err != nil {
+		return database.Group{}, database.GroupMember{}, newError(codeInternal, "Couldn't get group membership", err)
+	}
+
+	return group, membership, nil
 }
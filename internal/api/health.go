@@ -0,0 +1,154 @@
+package api
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+)
+
+// readinessTimeout bounds all readiness checks together, so that a hanging
+// database fails the probe instead of timing it out.
+const readinessTimeout = 2 * time.Second
+
+const (
+	healthOK   = "ok"
+	healthFail = "fail"
+	// healthDegraded is the overall status when only checks that aren't
+	// critical fail. The server still takes traffic then.
+	healthDegraded = "degraded"
+)
+
+type HealthReport struct {
+	Status string                 `json:"status"`
+	Checks map[string]HealthCheck `json:"checks,omitempty"`
+}
+
+type HealthCheck struct {
+	Status string `json:"status"`
+	// Critical checks make the server unready when they fail.
+	Critical bool           `json:"critical"`
+	Error    string         `json:"error,omitempty"`
+	Details  map[string]any `json:"details,omitempty"`
+}
+
+// StartShutdown makes readiness fail from now on, so load balancers stop
+// sending requests while the in-flight ones drain.
+func (cfg *Config) StartShutdown() {
+	cfg.shuttingDown.Store(true)
+}
+
+// handlerLivez godoc
+//
+//	@Router		/livez [get]
+//	@Summary	check if the process is alive
+//	@Description	Doesn't look at any dependency, so a failing database doesn't get the server restarted.
+//	@Tags		health
+//	@Produce	json
+//	@Success	200	{object}	HealthReport
+func (cfg *Config) handlerLivez(w http.ResponseWriter, r *http.Request) error {
+	w.Header().Set("Cache-Control", "no-store")
+	respondWithJSON(w, http.StatusOK, HealthReport{Status: healthOK})
+	return nil
+}
+
+// handlerReadyz godoc
+//
+//	@Router		/readyz [get]
+//	@Summary	check if the server can take traffic
+//	@Description	Pings the database, checks that its migrations are current and that the connection pool isn't exhausted. Responds with 503 when a critical check fails, including while the server shuts down.
+//	@Tags		health
+//	@Produce	json
+//	@Success	200	{object}	HealthReport
+//	@Failure	503	{object}	HealthReport
+func (cfg *Config) handlerReadyz(w http.ResponseWriter, r *http.Request) error {
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+
+	report := HealthReport{
+		Status: healthOK,
+		Checks: map[string]HealthCheck{
+			"server":     cfg.checkServer(),
+			"database":   cfg.checkDatabase(ctx),
+			"migrations": cfg.checkMigrations(ctx),
+			"db_pool":    cfg.checkDatabasePool(),
+		},
+	}
+
+	status := http.StatusOK
+	for _, check := range report.Checks {
+		if check.Status == healthOK {
+			continue
+		}
+		if check.Critical {
+			report.Status = healthFail
+			status = http.StatusServiceUnavailable
+		} else if report.Status == healthOK {
+			report.Status = healthDegraded
+		}
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	respondWithJSON(w, status, report)
+	return nil
+}
+
+func (cfg *Config) checkServer() HealthCheck {
+	if cfg.shuttingDown.Load() {
+		return HealthCheck{Status: healthFail, Critical: true, Error: "Server is shutting down"}
+	}
+	return HealthCheck{Status: healthOK, Critical: true}
+}
+
+func (cfg *Config) checkDatabase(ctx context.Context) HealthCheck {
+	start := time.Now()
+	err := cfg.sqlDB.PingContext(ctx)
+	details := map[string]any{"latency_ms": time.Since(start).Milliseconds()}
+	if err != nil {
+		// The endpoint is public, so the cause only goes to the log.
+		log.Printf("Readiness check: error pinging database: %v", err)
+		return HealthCheck{Status: healthFail, Critical: true, Error: "Database unreachable", Details: details}
+	}
+	return HealthCheck{Status: healthOK, Critical: true, Details: details}
+}
+
+// schemaVersionQuery reads the current version from goose's table the way
+// goose does: a version counts when its latest row says it is applied, as
+// rolling back adds a row rather than deleting one.
+const schemaVersionQuery = `
+SELECT COALESCE(MAX(version_id), 0) FROM goose_db_version v
+WHERE is_applied AND NOT EXISTS (
+	SELECT 1 FROM goose_db_version later
+	WHERE later.version_id = v.version_id AND later.id > v.id
+)`
+
+// checkMigrations fails while the database is behind the migrations the
+// server was built with. A database ahead of it is fine, since migrations
+// are applied before a new version rolls out.
+func (cfg *Config) checkMigrations(ctx context.Context) HealthCheck {
+	var version int64
+	err := cfg.sqlDB.QueryRowContext(ctx, schemaVersionQuery).Scan(&version)
+	if err != nil {
+		log.Printf("Readiness check: error reading schema version: %v", err)
+		return HealthCheck{Status: healthFail, Critical: true, Error: "Couldn't read schema version"}
+	}
+
+	details := map[string]any{"current": version, "expected": cfg.schemaVersion}
+	if version < cfg.schemaVersion {
+		return HealthCheck{Status: healthFail, Critical: true, Error: "Database migrations are behind", Details: details}
+	}
+	return HealthCheck{Status: healthOK, Critical: true, Details: details}
+}
+
+// checkDatabasePool fails when every connection is in use, which calls for a
+// larger pool, but the server is still ready then. The stats go to the log
+// rather than the public response.
+func (cfg *Config) checkDatabasePool() HealthCheck {
+	stats := cfg.sqlDB.Stats()
+	if stats.MaxOpenConnections > 0 && stats.InUse >= stats.MaxOpenConnections {
+		log.Printf("Readiness check: all %d database connections in use, %d waits totalling %v",
+			stats.MaxOpenConnections, stats.WaitCount, stats.WaitDuration)
+		return HealthCheck{Status: healthFail, Error: "All database connections are in use"}
+	}
+	return HealthCheck{Status: healthOK}
+}
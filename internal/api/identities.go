@@ -0,0 +1,169 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/markbates/goth"
+	"github.com/potom-dev/backend/internal/auth"
+	"github.com/potom-dev/backend/internal/database"
+	"github.com/potom-dev/backend/internal/pagination"
+)
+
+type Identity struct {
+	Id        uuid.UUID `json:"id"`
+	Provider  string    `json:"provider"`
+	Email     string    `json:"email,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+var errLastSignInMethod = errors.New("user has no other way to sign in")
+
+func identityFromDB(identity database.UserIdentity) Identity {
+	return Identity{
+		Id:        identity.ID,
+		Provider:  identity.Provider,
+		Email:     identity.Email.String,
+		CreatedAt: identity.CreatedAt,
+	}
+}
+
+type LinkIdentityResponse struct {
+	Url string `json:"url"`
+}
+
+// handlerGetIdentities godoc
+//
+//	@Router		/identities [get]
+//	@Summary	list the OAuth identities linked to the caller's account
+//	@Tags		identities
+//	@Accept		json
+//	@Produce	json
+//	@Param		Authorization	header	string	true	"Bearer token"
+//	@Param		limit	query	int		false	"Page size, 1 to 200"	default(50)
+//	@Param		cursor	query	string	false	"next_cursor of the previous page"
+//	@Success	200	{object}	pagination.Page[Identity]
+//	@Failure	400	{object}	Problem
+//	@Failure	401	{object}	Problem
+//	@Failure	500	{object}	Problem
+//	@Security	BearerAuth
+func (cfg *Config) handlerGetIdentities(w http.ResponseWriter, r *http.Request) error {
+	page, err := parsePage(r, "created_at")
+	if err != nil {
+		return err
+	}
+
+	identities, err := cfg.db.ListUserIdentities(r.Context(), database.ListUserIdentitiesParams{
+		UserID:         UserIDFromContext(r.Context()),
+		AfterCreatedAt: page.AfterTime(),
+		AfterID:        page.AfterID(),
+		Limit:          page.FetchLimit(),
+	})
+	if err != nil {
+		return newError(codeInternal, "Couldn't get identities", err)
+	}
+
+	cursor := func(identity database.UserIdentity) pagination.Cursor {
+		return pagination.Cursor{Time: &identity.CreatedAt, ID: identity.ID}
+	}
+
+	respondWithJSON(w, http.StatusOK, pagination.NewPage(identities, page, cursor, identityFromDB))
+	return nil
+}
+
+// handlerLinkIdentity godoc
+//
+//	@Router		/identities/{provider}/link [post]
+//	@Summary	start linking an OAuth provider to the caller's account
+//	@Description	Sets a cookie holding a link code and returns the URL to send the same browser to. The flow only links when that cookie comes along, so the URL is useless in any other browser. Once the provider flow completes, the browser is redirected back to the app with a linked or error query parameter.
+//	@Tags		identities
+//	@Accept		json
+//	@Produce	json
+//	@Param		Authorization	header	string	true	"Bearer token"
+//	@Param		provider	path		string	true	"Provider name"
+//	@Success	200			{object}	LinkIdentityResponse
+//	@Failure	401			{object}	Problem
+//	@Failure	404			{object}	Problem
+//	@Failure	500			{object}	Problem
+//	@Security	BearerAuth
+func (cfg *Config) handlerLinkIdentity(w http.ResponseWriter, r *http.Request) error {
+	provider := r.PathValue("provider")
+	if _, err := goth.GetProvider(provider); err != nil {
+		return newError(codeNotFound, "Unknown provider", err)
+	}
+
+	code, err := cfg.createOauthCode(r.Context(), UserIDFromContext(r.Context()), oauthCodePurposeLink)
+	if err != nil {
+		return newError(codeInternal, "Couldn't create link code", err)
+	}
+
+	cfg.setLinkCookie(w, provider, code)
+	respondWithJSON(w, http.StatusOK, LinkIdentityResponse{
+		Url: "/api/auth/" + url.PathEscape(provider) + "?link=true",
+	})
+	return nil
+}
+
+// handlerUnlinkIdentity godoc
+//
+//	@Router		/identities/{identityId} [delete]
+//	@Summary	unlink an OAuth identity from the caller's account
+//	@Tags		identities
+//	@Accept		json
+//	@Produce	json
+//	@Param		Authorization	header	string	true	"Bearer token"
+//	@Param		identityId	path	string	true	"Identity ID"
+//	@Success	204			"No Content"
+//	@Failure	400			{object}	Problem
+//	@Failure	401			{object}	Problem
+//	@Failure	404			{object}	Problem
+//	@Failure	409			{object}	Problem
+//	@Failure	500			{object}	Problem
+//	@Security	BearerAuth
+func (cfg *Config) handlerUnlinkIdentity(w http.ResponseWriter, r *http.Request) error {
+	identityID, err := uuid.Parse(r.PathValue("identityId"))
+	if err != nil {
+		return newError(codeInvalidRequest, "Invalid identity ID", err)
+	}
+
+	userID := UserIDFromContext(r.Context())
+
+	var deleted int64
+	err = cfg.withTx(r.Context(), func(q *database.Queries) error {
+		// The user row stays locked until the transaction ends, so two
+		// unlinks at once can't both count the other's identity.
+		user, err := q.LockUserById(r.Context(), userID)
+		if err != nil {
+			return err
+		}
+
+		identities, err := q.CountUserIdentities(r.Context(), userID)
+		if err != nil {
+			return err
+		}
+		if user.PasswordHash == auth.NoPassword && identities < 2 {
+			return errLastSignInMethod
+		}
+
+		deleted, err = q.DeleteUserIdentity(r.Context(), database.DeleteUserIdentityParams{
+			ID:     identityID,
+			UserID: userID,
+		})
+		return err
+	})
+	if errors.Is(err, errLastSignInMethod) {
+		return newError(codeConflict, "Set a password or link another provider before unlinking this one", nil)
+	}
+	if err != nil {
+		return newError(codeInternal, "Couldn't unlink identity", err)
+	}
+	if deleted == 0 {
+		return newError(codeNotFound, "Identity not found", nil)
+	}
+
+	respondWithJSON(w, http.StatusNoContent, nil)
+	return nil
+}
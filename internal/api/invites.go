@@ -0,0 +1,489 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/potom-dev/backend/internal/auth"
+	"github.com/potom-dev/backend/internal/database"
+	"github.com/potom-dev/backend/internal/pagination"
+	"github.com/potom-dev/backend/internal/validation"
+)
+
+const (
+	defaultInviteLifetime = time.Hour * 24 * 7
+	maxInviteLifetime     = time.Hour * 24 * 30
+)
+
+var (
+	errInviteUnavailable = errors.New("invite is no longer valid")
+	errInviteRecipient   = errors.New("invite was issued to a different email")
+	errInviteUnverified  = errors.New("invite recipient's email is not verified")
+	errAlreadyMember     = errors.New("user is already a member")
+)
+
+type CreateGroupInviteParams struct {
+	Email        string `json:"email,omitempty"`
+	Role         string `json:"role,omitempty"`
+	MaxUses      int32  `json:"max_uses,omitempty"`
+	ExpiresInSec int64  `json:"expires_in_seconds,omitempty"`
+}
+
+func (p CreateGroupInviteParams) Validate(v *validation.Validator) {
+	if p.Email != "" {
+		v.Email("email", strings.TrimSpace(p.Email))
+	}
+	if p.Role != "" {
+		v.OneOf("role", p.Role, groupRoleMember, groupRoleAdmin)
+	}
+	v.NonNegative("max_uses", int64(p.MaxUses))
+	v.NonNegative("expires_in_seconds", p.ExpiresInSec)
+}
+
+type GroupInvite struct {
+	Id        uuid.UUID `json:"id"`
+	GroupId   uuid.UUID `json:"group_id"`
+	CreatedBy uuid.UUID `json:"created_by"`
+	Email     string    `json:"email,omitempty"`
+	Role      string    `json:"role"`
+	MaxUses   *int32    `json:"max_uses,omitempty"`
+	Uses      int32     `json:"uses"`
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type CreateGroupInviteResponse struct {
+	GroupInvite
+	Token string `json:"token"`
+}
+
+type InvitePreview struct {
+	GroupId   uuid.UUID `json:"group_id"`
+	GroupName string    `json:"group_name"`
+	Email     string    `json:"email,omitempty"`
+	Role      string    `json:"role"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func groupInviteFromDB(invite database.GroupInvite) GroupInvite {
+	resp := GroupInvite{
+		Id:        invite.ID,
+		GroupId:   invite.GroupID,
+		CreatedBy: invite.CreatedBy,
+		Email:     invite.Email.String,
+		Role:      invite.Role,
+		Uses:      invite.Uses,
+		ExpiresAt: invite.ExpiresAt,
+		CreatedAt: invite.CreatedAt,
+	}
+	if invite.MaxUses.Valid {
+		resp.MaxUses = &invite.MaxUses.Int32
+	}
+	return resp
+}
+
+// checkInviteUsable reports whether an invite can still be accepted.
+func checkInviteUsable(invite database.GroupInvite) error {
+	if invite.RevokedAt.Valid || invite.DeclinedAt.Valid {
+		return errInviteUnavailable
+	}
+	if invite.ExpiresAt.Before(time.Now()) {
+		return errInviteUnavailable
+	}
+	if invite.MaxUses.Valid && invite.Uses >= invite.MaxUses.Int32 {
+		return errInviteUnavailable
+	}
+	return nil
+}
+
+// checkInviteRecipient reports whether user may answer an invite. An email
+// invite only belongs to the owner of the email, so the user's email must
+// match it and be verified whether or not REQUIRE_VERIFIED_EMAIL is set.
+func checkInviteRecipient(invite database.GroupInvite, user database.User) error {
+	if !invite.Email.Valid {
+		return nil
+	}
+	if !strings.EqualFold(invite.Email.String, user.Email) {
+		return errInviteRecipient
+	}
+	if !user.EmailVerifiedAt.Valid {
+		return errInviteUnverified
+	}
+	return nil
+}
+
+// handlerCreateGroupInvite godoc
+//
+//	@Router		/groups/{groupId}/invites [post]
+//	@Summary	create a group invite link or email invite
+//	@Tags		invites
+//	@Accept		json
+//	@Produce	json
+//	@Param		Authorization	header	string	true	"Bearer token"
+//	@Param		groupId	path		string					true	"Group ID"
+//	@Param		body	body		CreateGroupInviteParams	true	"Invite parameters"
+//	@Success	201		{object}	CreateGroupInviteResponse
+//	@Failure	400		{object}	Problem
+//	@Failure	401		{object}	Problem
+//	@Failure	403		{object}	Problem
+//	@Failure	404		{object}	Problem
+//	@Failure	422		{object}	Problem
+//	@Failure	500		{object}	Problem
+//	@Security	BearerAuth
+func (cfg *Config) handlerCreateGroupInvite(w http.ResponseWriter, r *http.Request) error {
+	group, membership, err := cfg.loadGroupMembership(r)
+	if err != nil {
+		return err
+	}
+
+	params := CreateGroupInviteParams{}
+	if err := decodeParams(w, r, &params); err != nil {
+		return err
+	}
+
+	if params.Role == "" {
+		params.Role = groupRoleMember
+	}
+
+	if !groupRoleAtLeast(membership.Role, groupRoleAdmin) {
+		return newError(codeForbidden, "Only group owners and admins can invite members", nil)
+	}
+	if params.Role != groupRoleMember && membership.Role != groupRoleOwner {
+		return newError(codeForbidden, "Only group owners can grant elevated roles", nil)
+	}
+
+	lifetime := defaultInviteLifetime
+	if params.ExpiresInSec > 0 {
+		lifetime = min(time.Duration(params.ExpiresInSec)*time.Second, maxInviteLifetime)
+	}
+
+	maxUses := sql.NullInt32{Int32: params.MaxUses, Valid: params.MaxUses > 0}
+	email := sql.NullString{String: strings.TrimSpace(params.Email), Valid: strings.TrimSpace(params.Email) != ""}
+	if email.Valid {
+		// Email invites are personal, so they can only be used once.
+		maxUses = sql.NullInt32{Int32: 1, Valid: true}
+	}
+
+	token, err := auth.MakeRefreshToken()
+	if err != nil {
+		return newError(codeInternal, "Couldn't create invite token", err)
+	}
+
+	invite, err := cfg.db.CreateGroupInvite(r.Context(), database.CreateGroupInviteParams{
+		GroupID:   group.ID,
+		CreatedBy: membership.UserID,
+		TokenHash: auth.HashToken(token),
+		Email:     email,
+		Role:      params.Role,
+		MaxUses:   maxUses,
+		ExpiresAt: time.Now().Add(lifetime),
+	})
+	if err != nil {
+		return newError(codeInternal, "Couldn't create invite", err)
+	}
+
+	if email.Valid {
+		cfg.sendMail(groupInviteEmail(email.String, group.Name, cfg.appLink("/invite", token)))
+	}
+
+	respondWithJSON(w, http.StatusCreated, CreateGroupInviteResponse{
+		GroupInvite: groupInviteFromDB(invite),
+		Token:       token,
+	})
+	return nil
+}
+
+// handlerGetGroupInvites godoc
+//
+//	@Router		/groups/{groupId}/invites [get]
+//	@Summary	list pending group invites
+//	@Tags		invites
+//	@Accept		json
+//	@Produce	json
+//	@Param		Authorization	header	string	true	"Bearer token"
+//	@Param		groupId	path		string	true	"Group ID"
+//	@Param		limit	query		int		false	"Page size, 1 to 200"	default(50)
+//	@Param		cursor	query		string	false	"next_cursor of the previous page"
+//	@Success	200		{object}	pagination.Page[GroupInvite]
+//	@Failure	400		{object}	Problem
+//	@Failure	401		{object}	Problem
+//	@Failure	403		{object}	Problem
+//	@Failure	404		{object}	Problem
+//	@Failure	500		{object}	Problem
+//	@Security	BearerAuth
+func (cfg *Config) handlerGetGroupInvites(w http.ResponseWriter, r *http.Request) error {
+	group, membership, err := cfg.loadGroupMembership(r)
+	if err != nil {
+		return err
+	}
+
+	if !groupRoleAtLeast(membership.Role, groupRoleAdmin) {
+		return newError(codeForbidden, "Only group owners and admins can view invites", nil)
+	}
+
+	page, err := parsePage(r, "created_at")
+	if err != nil {
+		return err
+	}
+
+	invites, err := cfg.db.ListPendingGroupInvites(r.Context(), database.ListPendingGroupInvitesParams{
+		GroupID:        group.ID,
+		AfterCreatedAt: page.AfterTime(),
+		AfterID:        page.AfterID(),
+		Limit:          page.FetchLimit(),
+	})
+	if err != nil {
+		return newError(codeInternal, "Couldn't get invites", err)
+	}
+
+	cursor := func(invite database.GroupInvite) pagination.Cursor {
+		return pagination.Cursor{Time: &invite.CreatedAt, ID: invite.ID}
+	}
+
+	respondWithJSON(w, http.StatusOK, pagination.NewPage(invites, page, cursor, groupInviteFromDB))
+	return nil
+}
+
+// handlerRevokeGroupInvite godoc
+//
+//	@Router		/groups/{groupId}/invites/{inviteId} [delete]
+//	@Summary	revoke a group invite
+//	@Tags		invites
+//	@Accept		json
+//	@Produce	json
+//	@Param		Authorization	header	string	true	"Bearer token"
+//	@Param		groupId		path	string	true	"Group ID"
+//	@Param		inviteId	path	string	true	"Invite ID"
+//	@Success	204		"No Content"
+//	@Failure	400		{object}	Problem
+//	@Failure	401		{object}	Problem
+//	@Failure	403		{object}	Problem
+//	@Failure	404		{object}	Problem
+//	@Failure	500		{object}	Problem
+//	@Security	BearerAuth
+func (cfg *Config) handlerRevokeGroupInvite(w http.ResponseWriter, r *http.Request) error {
+	group, membership, err := cfg.loadGroupMembership(r)
+	if err != nil {
+		return err
+	}
+
+	if !groupRoleAtLeast(membership.Role, groupRoleAdmin) {
+		return newError(codeForbidden, "Only group owners and admins can revoke invites", nil)
+	}
+
+	inviteID, err := uuid.Parse(r.PathValue("inviteId"))
+	if err != nil {
+		return newError(codeInvalidRequest, "Invalid invite ID", err)
+	}
+
+	revoked, err := cfg.db.RevokeGroupInvite(r.Context(), database.RevokeGroupInviteParams{
+		ID:      inviteID,
+		GroupID: group.ID,
+	})
+	if err != nil {
+		return newError(codeInternal, "Couldn't revoke invite", err)
+	}
+	if revoked == 0 {
+		return newError(codeNotFound, "Invite not found", nil)
+	}
+
+	respondWithJSON(w, http.StatusNoContent, nil)
+	return nil
+}
+
+// handlerGetInvite godoc
+//
+//	@Router		/invites/{token} [get]
+//	@Summary	preview an invite
+//	@Tags		invites
+//	@Accept		json
+//	@Produce	json
+//	@Param		Authorization	header	string	true	"Bearer token"
+//	@Param		token	path		string	true	"Invite token"
+//	@Success	200		{object}	InvitePreview
+//	@Failure	401		{object}	Problem
+//	@Failure	404		{object}	Problem
+//	@Failure	410		{object}	Problem
+//	@Failure	500		{object}	Problem
+//	@Security	BearerAuth
+func (cfg *Config) handlerGetInvite(w http.ResponseWriter, r *http.Request) error {
+	invite, err := cfg.db.GetGroupInviteByTokenHash(r.Context(), auth.HashToken(r.PathValue("token")))
+	if errors.Is(err, sql.ErrNoRows) {
+		return newError(codeNotFound, "Invite not found", nil)
+	}
+	if err != nil {
+		return newError(codeInternal, "Couldn't get invite", err)
+	}
+
+	if err := checkInviteUsable(invite); err != nil {
+		return newError(codeGone, "Invite is no longer valid", nil)
+	}
+
+	group, err := cfg.db.GetGroupById(r.Context(), invite.GroupID)
+	if err != nil {
+		return newError(codeInternal, "Couldn't get group", err)
+	}
+
+	respondWithJSON(w, http.StatusOK, InvitePreview{
+		GroupId:   group.ID,
+		GroupName: group.Name,
+		Email:     invite.Email.String,
+		Role:      invite.Role,
+		ExpiresAt: invite.ExpiresAt,
+	})
+	return nil
+}
+
+// handlerAcceptInvite godoc
+//
+//	@Router		/invites/{token}/accept [post]
+//	@Summary	accept an invite and join the group
+//	@Tags		invites
+//	@Accept		json
+//	@Produce	json
+//	@Param		Authorization	header	string	true	"Bearer token"
+//	@Param		token	path		string	true	"Invite token"
+//	@Success	200		{object}	Group
+//	@Failure	401		{object}	Problem
+//	@Failure	403		{object}	Problem
+//	@Failure	404		{object}	Problem
+//	@Failure	409		{object}	Problem
+//	@Failure	410		{object}	Problem
+//	@Failure	500		{object}	Problem
+//	@Security	BearerAuth
+func (cfg *Config) handlerAcceptInvite(w http.ResponseWriter, r *http.Request) error {
+	if err := cfg.requireVerifiedEmail(r); err != nil {
+		return err
+	}
+
+	user, err := cfg.db.GetUserById(r.Context(), UserIDFromContext(r.Context()))
+	if err != nil {
+		return newError(codeInternal, "Couldn't get user", err)
+	}
+
+	var groupID uuid.UUID
+	err = cfg.withTx(r.Context(), func(q *database.Queries) error {
+		invite, err := q.LockGroupInviteByTokenHash(r.Context(), auth.HashToken(r.PathValue("token")))
+		if err != nil {
+			return err
+		}
+
+		if err := checkInviteUsable(invite); err != nil {
+			return err
+		}
+		if err := checkInviteRecipient(invite, user); err != nil {
+			return err
+		}
+
+		_, err = q.GetGroupMember(r.Context(), database.GetGroupMemberParams{
+			GroupID: invite.GroupID,
+			UserID:  user.ID,
+		})
+		if err == nil {
+			return errAlreadyMember
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+
+		_, err = q.AddGroupMember(r.Context(), database.AddGroupMemberParams{
+			GroupID: invite.GroupID,
+			UserID:  user.ID,
+			Role:    invite.Role,
+		})
+		if err != nil {
+			return err
+		}
+
+		groupID = invite.GroupID
+		return q.IncrementGroupInviteUses(r.Context(), invite.ID)
+	})
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return newError(codeNotFound, "Invite not found", nil)
+	case errors.Is(err, errInviteUnavailable):
+		return newError(codeGone, "Invite is no longer valid", nil)
+	case errors.Is(err, errInviteRecipient):
+		return newError(codeForbidden, "Invite was issued to a different email", nil)
+	case errors.Is(err, errInviteUnverified):
+		return newError(codeEmailNotVerified, "Verify your email address to accept this invite", nil)
+	case errors.Is(err, errAlreadyMember):
+		return newError(codeConflict, "User is already a member", nil)
+	case err != nil:
+		return newError(codeInternal, "Couldn't accept invite", err)
+	}
+
+	group, err := cfg.db.GetGroupById(r.Context(), groupID)
+	if err != nil {
+		return newError(codeInternal, "Couldn't get group", err)
+	}
+
+	respondWithJSON(w, http.StatusOK, groupFromDB(group))
+	return nil
+}
+
+// handlerDeclineInvite godoc
+//
+//	@Router		/invites/{token}/decline [post]
+//	@Summary	decline an invite
+//	@Tags		invites
+//	@Accept		json
+//	@Produce	json
+//	@Param		Authorization	header	string	true	"Bearer token"
+//	@Param		token	path	string	true	"Invite token"
+//	@Success	204		"No Content"
+//	@Failure	401		{object}	Problem
+//	@Failure	403		{object}	Problem
+//	@Failure	404		{object}	Problem
+//	@Failure	410		{object}	Problem
+//	@Failure	500		{object}	Problem
+//	@Security	BearerAuth
+func (cfg *Config) handlerDeclineInvite(w http.ResponseWriter, r *http.Request) error {
+	invite, err := cfg.db.GetGroupInviteByTokenHash(r.Context(), auth.HashToken(r.PathValue("token")))
+	if errors.Is(err, sql.ErrNoRows) {
+		return newError(codeNotFound, "Invite not found", nil)
+	}
+	if err != nil {
+		return newError(codeInternal, "Couldn't get invite", err)
+	}
+
+	if checkInviteUsable(invite) != nil {
+		return newError(codeGone, "Invite is no longer valid", nil)
+	}
+
+	// Link invites are shared, so declining one only matters to the caller
+	// and there is nothing to record.
+	if !invite.Email.Valid {
+		respondWithJSON(w, http.StatusNoContent, nil)
+		return nil
+	}
+
+	user, err := cfg.db.GetUserById(r.Context(), UserIDFromContext(r.Context()))
+	if err != nil {
+		return newError(codeInternal, "Couldn't get user", err)
+	}
+
+	err = checkInviteRecipient(invite, user)
+	if errors.Is(err, errInviteRecipient) {
+		return newError(codeForbidden, "Invite was issued to a different email", nil)
+	}
+	if errors.Is(err, errInviteUnverified) {
+		return newError(codeEmailNotVerified, "Verify your email address to decline this invite", nil)
+	}
+
+	declined, err := cfg.db.DeclineGroupInvite(r.Context(), invite.ID)
+	if err != nil {
+		return newError(codeInternal, "Couldn't decline invite", err)
+	}
+	// The invite was revoked or declined since it was read.
+	if declined == 0 {
+		return newError(codeGone, "Invite is no longer valid", nil)
+	}
+
+	respondWithJSON(w, http.StatusNoContent, nil)
+	return nil
+}
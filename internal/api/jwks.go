@@ -0,0 +1,12 @@
+package api
+
+import "net/http"
+
+// handlerJWKS publishes the public keys access tokens are signed with, so
+// other services can verify them without sharing a secret. It is served
+// outside /api at the well-known path verifiers look for.
+func (cfg *Config) handlerJWKS(w http.ResponseWriter, r *http.Request) error {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	respondWithJSON(w, http.StatusOK, cfg.jwtKeys.JWKS())
+	return nil
+}
@@ -0,0 +1,136 @@
+package api
+
+import (
+	"context"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/potom-dev/backend/internal/database"
+	"github.com/potom-dev/backend/internal/lockout"
+)
+
+// accountLoginPolicy throttles guessing against a single account, wherever
+// the guesses come from.
+var accountLoginPolicy = lockout.Policy{
+	FreeAttempts:     3,
+	BaseDelay:        time.Second,
+	MaxDelay:         time.Minute * 5,
+	LockoutThreshold: 10,
+	LockoutDuration:  time.Minute * 15,
+	Window:           time.Hour,
+}
+
+// ipLoginPolicy throttles a single address trying many accounts. It is more
+// lenient since many users can share an address.
+var ipLoginPolicy = lockout.Policy{
+	FreeAttempts:     20,
+	BaseDelay:        time.Second,
+	MaxDelay:         time.Minute * 5,
+	LockoutThreshold: 100,
+	LockoutDuration:  time.Hour,
+	Window:           time.Hour,
+}
+
+func accountLoginKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipLoginKey(r *http.Request) string {
+	return "ip:" + clientIP(r)
+}
+
+// loginAttempt is a login, or an answer to an MFA challenge, that has
+// reserved an attempt against both the account and the client's address.
+// The reservations are taken before the credentials are checked so that
+// parallel guesses can't all get in before the first of them fails.
+type loginAttempt struct {
+	accountKey string
+	ipKey      string
+	account    *lockout.Reservation
+	ip         *lockout.Reservation
+	failed     bool
+}
+
+// startLoginAttempt reserves an attempt to log in to the account with
+// accountKey, or returns an error telling the client to come back later. The
+// caller must finish the attempt.
+func (cfg *Config) startLoginAttempt(w http.ResponseWriter, r *http.Request, accountKey string) (*loginAttempt, error) {
+	attempt := &loginAttempt{accountKey: accountKey, ipKey: ipLoginKey(r)}
+
+	account, accountWait, err := cfg.accountLimiter.Reserve(r.Context(), attempt.accountKey)
+	if err != nil {
+		return nil, newError(codeInternal, "Couldn't check login attempts", err)
+	}
+	attempt.account = account
+
+	ip, ipWait, err := cfg.ipLimiter.Reserve(r.Context(), attempt.ipKey)
+	if err != nil {
+		cfg.finishLoginAttempt(r, attempt)
+		return nil, newError(codeInternal, "Couldn't check login attempts", err)
+	}
+	attempt.ip = ip
+
+	if wait := max(accountWait, ipWait); wait > 0 {
+		cfg.finishLoginAttempt(r, attempt)
+		setRetryAfter(w, wait)
+		return nil, newError(codeRateLimited, "Too many failed login attempts, try again later", nil)
+	}
+	return attempt, nil
+}
+
+// failLoginAttempt keeps the attempt counted as a failure, auditing any
+// lockout it causes. userID is null when no account has the email.
+func (cfg *Config) failLoginAttempt(r *http.Request, attempt *loginAttempt, userID uuid.NullUUID) {
+	attempt.failed = true
+
+	if attempt.account.LockedOut() {
+		log.Printf("Locking out %s after repeated failed logins", attempt.accountKey)
+		cfg.recordAuditEvent(r, userID, auditEventLoginLockedOut, attempt.accountKey)
+	}
+	if attempt.ip.LockedOut() {
+		log.Printf("Locking out %s after repeated failed logins", attempt.ipKey)
+		cfg.recordAuditEvent(r, uuid.NullUUID{}, auditEventLoginLockedOut, attempt.ipKey)
+	}
+}
+
+// finishLoginAttempt gives back the reservations of an attempt that didn't
+// fail, whether it succeeded or stopped for another reason.
+func (cfg *Config) finishLoginAttempt(r *http.Request, attempt *loginAttempt) {
+	if attempt.failed {
+		return
+	}
+
+	// The request may have been cancelled, which mustn't leave the
+	// reservations counted as failures.
+	ctx := context.WithoutCancel(r.Context())
+	if attempt.account != nil {
+		if err := attempt.account.Release(ctx); err != nil {
+			log.Printf("Error releasing login attempt for %s: %v", attempt.accountKey, err)
+		}
+	}
+	if attempt.ip != nil {
+		if err := attempt.ip.Release(ctx); err != nil {
+			log.Printf("Error releasing login attempt for %s: %v", attempt.ipKey, err)
+		}
+	}
+}
+
+// resetLoginFailures forgets the failed logins of user's account once it has
+// passed every factor. Passing only the password doesn't count, or the
+// second factor could be guessed a challenge at a time.
+func (cfg *Config) resetLoginFailures(ctx context.Context, user database.User) {
+	accountKey := accountLoginKey(user.Email)
+	if err := cfg.accountLimiter.Reset(ctx, accountKey); err != nil {
+		log.Printf("Error resetting login attempts for %s: %v", accountKey, err)
+	}
+}
+
+// setRetryAfter tells a throttled client how many seconds to wait.
+func setRetryAfter(w http.ResponseWriter, wait time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/potom-dev/backend/internal/lockout"
+)
+
+// mailAddressPolicy throttles the emails anyone can have sent to a single
+// address, so the endpoints that send them can't flood an inbox.
+var mailAddressPolicy = lockout.Policy{
+	FreeAttempts:     3,
+	BaseDelay:        time.Minute,
+	MaxDelay:         time.Minute * 15,
+	LockoutThreshold: 10,
+	LockoutDuration:  time.Hour,
+	Window:           time.Hour,
+}
+
+// mailIPPolicy throttles a single address requesting emails to many others.
+var mailIPPolicy = lockout.Policy{
+	FreeAttempts:     20,
+	BaseDelay:        time.Second,
+	MaxDelay:         time.Minute * 5,
+	LockoutThreshold: 100,
+	LockoutDuration:  time.Hour,
+	Window:           time.Hour,
+}
+
+// limitMailRequest counts a request that emails the given address against
+// both that address and the client's address, or returns an error telling
+// the client to come back later. A request counts whether or not an email
+// goes out, so the limits don't tell who has an account.
+func (cfg *Config) limitMailRequest(w http.ResponseWriter, r *http.Request, email string) error {
+	addressKey := "mail:" + accountLoginKey(email)
+	ipKey := "mail:" + ipLoginKey(r)
+
+	address, addressWait, err := cfg.mailAddressLimiter.Reserve(r.Context(), addressKey)
+	if err != nil {
+		return newError(codeInternal, "Couldn't check email requests", err)
+	}
+
+	ip, ipWait, err := cfg.mailIPLimiter.Reserve(r.Context(), ipKey)
+	if err != nil {
+		releaseMailRequest(r, address, addressKey)
+		return newError(codeInternal, "Couldn't check email requests", err)
+	}
+
+	if wait := max(addressWait, ipWait); wait > 0 {
+		// A refused request sends nothing, so it doesn't count.
+		releaseMailRequest(r, address, addressKey)
+		releaseMailRequest(r, ip, ipKey)
+		setRetryAfter(w, wait)
+		return newError(codeRateLimited, "Too many requests, try again later", nil)
+	}
+	return nil
+}
+
+func releaseMailRequest(r *http.Request, reservation *lockout.Reservation, key string) {
+	if reservation == nil {
+		return
+	}
+	if err := reservation.Release(context.WithoutCancel(r.Context())); err != nil {
+		log.Printf("Error releasing email request for %s: %v", key, err)
+	}
+}
@@ -0,0 +1,430 @@
+package api
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/potom-dev/backend/internal/auth"
+	"github.com/potom-dev/backend/internal/database"
+	"github.com/potom-dev/backend/internal/validation"
+)
+
+const (
+	totpIssuer = "potom"
+
+	recoveryCodeCount = 10
+
+	mfaChallengeLifetime    = time.Minute * 5
+	mfaChallengeMaxAttempts = 5
+)
+
+var errInvalidSecondFactor = errors.New("invalid second factor")
+
+type MfaChallengeResponse struct {
+	MfaRequired bool   `json:"mfa_required"`
+	MfaToken    string `json:"mfa_token"`
+}
+
+type TotpSetupResponse struct {
+	Secret     string `json:"secret"`
+	OtpauthUri string `json:"otpauth_uri"`
+}
+
+type TotpConfirmParams struct {
+	Code string `json:"code"`
+}
+
+func (p TotpConfirmParams) Validate(v *validation.Validator) {
+	v.Required("code", p.Code)
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type MfaVerifyParams struct {
+	MfaToken     string `json:"mfa_token"`
+	Code         string `json:"code,omitempty"`
+	RecoveryCode string `json:"recovery_code,omitempty"`
+	ExpiresInSec int64  `json:"expires_in_seconds,omitempty"`
+}
+
+func (p MfaVerifyParams) Validate(v *validation.Validator) {
+	v.Required("mfa_token", p.MfaToken)
+	v.Check(p.Code != "" || p.RecoveryCode != "", "code", "or recovery_code is required")
+	v.NonNegative("expires_in_seconds", p.ExpiresInSec)
+}
+
+type DisableTotpParams struct {
+	Password     string `json:"password,omitempty"`
+	Code         string `json:"code,omitempty"`
+	RecoveryCode string `json:"recovery_code,omitempty"`
+}
+
+// handlerSetupTotp godoc
+//
+//	@Router		/mfa/totp/setup [post]
+//	@Summary	start enrolling a TOTP authenticator
+//	@Description	Returns a new secret that only takes effect once confirmed with a code from the authenticator.
+//	@Tags		mfa
+//	@Accept		json
+//	@Produce	json
+//	@Param		Authorization	header	string	true	"Bearer token"
+//	@Success	200	{object}	TotpSetupResponse
+//	@Failure	401	{object}	Problem
+//	@Failure	409	{object}	Problem
+//	@Failure	500	{object}	Problem
+//	@Security	BearerAuth
+func (cfg *Config) handlerSetupTotp(w http.ResponseWriter, r *http.Request) error {
+	user, err := cfg.db.GetUserById(r.Context(), UserIDFromContext(r.Context()))
+	if err != nil {
+		return newError(codeInternal, "Couldn't get user", err)
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		return newError(codeInternal, "Couldn't generate secret", err)
+	}
+
+	_, err = cfg.db.UpsertUserTotp(r.Context(), database.UpsertUserTotpParams{
+		UserID: user.ID,
+		Secret: secret,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		// The upsert leaves confirmed secrets alone.
+		return newError(codeConflict, "Two-factor authentication is already enabled", nil)
+	}
+	if err != nil {
+		return newError(codeInternal, "Couldn't save secret", err)
+	}
+
+	respondWithJSON(w, http.StatusOK, TotpSetupResponse{
+		Secret:     secret,
+		OtpauthUri: auth.TOTPURI(totpIssuer, user.Email, secret),
+	})
+	return nil
+}
+
+// handlerConfirmTotp godoc
+//
+//	@Router		/mfa/totp/confirm [post]
+//	@Summary	enable TOTP with a code from the authenticator
+//	@Description	Returns ten single use recovery codes. They are not shown again.
+//	@Tags		mfa
+//	@Accept		json
+//	@Produce	json
+//	@Param		Authorization	header	string	true	"Bearer token"
+//	@Param		body	body		TotpConfirmParams	true	"Current code"
+//	@Success	200		{object}	RecoveryCodesResponse
+//	@Failure	400		{object}	Problem
+//	@Failure	401		{object}	Problem
+//	@Failure	404		{object}	Problem
+//	@Failure	409		{object}	Problem
+//	@Failure	422		{object}	Problem
+//	@Failure	500		{object}	Problem
+//	@Security	BearerAuth
+func (cfg *Config) handlerConfirmTotp(w http.ResponseWriter, r *http.Request) error {
+	params := TotpConfirmParams{}
+	if err := decodeParams(w, r, &params); err != nil {
+		return err
+	}
+
+	userID := UserIDFromContext(r.Context())
+
+	totp, err := cfg.db.GetUserTotp(r.Context(), userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return newError(codeNotFound, "Two-factor setup has not been started", nil)
+	}
+	if err != nil {
+		return newError(codeInternal, "Couldn't get MFA settings", err)
+	}
+
+	if totp.ConfirmedAt.Valid {
+		return newError(codeConflict, "Two-factor authentication is already enabled", nil)
+	}
+
+	step, err := auth.ValidateTOTP(totp.Secret, params.Code, time.Now())
+	if err != nil {
+		return newError(codeInvalidRequest, "Invalid code", err)
+	}
+
+	recoveryCodes := []string{}
+	err = cfg.withTx(r.Context(), func(q *database.Queries) error {
+		_, err := q.UseTotpStep(r.Context(), database.UseTotpStepParams{
+			UserID:       userID,
+			LastUsedStep: step,
+		})
+		if err != nil {
+			return err
+		}
+
+		err = q.ConfirmUserTotp(r.Context(), userID)
+		if err != nil {
+			return err
+		}
+
+		err = q.DeleteRecoveryCodes(r.Context(), userID)
+		if err != nil {
+			return err
+		}
+
+		for range recoveryCodeCount {
+			code, err := auth.MakeRecoveryCode()
+			if err != nil {
+				return err
+			}
+
+			err = q.CreateRecoveryCode(r.Context(), database.CreateRecoveryCodeParams{
+				UserID:   userID,
+				CodeHash: auth.HashToken(auth.NormalizeRecoveryCode(code)),
+			})
+			if err != nil {
+				return err
+			}
+
+			recoveryCodes = append(recoveryCodes, code)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return newError(codeInternal, "Couldn't enable two-factor authentication", err)
+	}
+
+	respondWithJSON(w, http.StatusOK, RecoveryCodesResponse{
+		RecoveryCodes: recoveryCodes,
+	})
+	return nil
+}
+
+// handlerVerifyMfa godoc
+//
+//	@Router		/mfa/verify [post]
+//	@Summary	finish logging in with a TOTP or recovery code
+//	@Tags		mfa
+//	@Accept		json
+//	@Produce	json
+//	@Param		body	body		MfaVerifyParams	true	"MFA token from login and a code"
+//	@Success	200		{object}	LoginResponse
+//	@Failure	400		{object}	Problem
+//	@Failure	401		{object}	Problem
+//	@Failure	403		{object}	Problem
+//	@Failure	422		{object}	Problem
+//	@Failure	429		{object}	Problem
+//	@Failure	500		{object}	Problem
+func (cfg *Config) handlerVerifyMfa(w http.ResponseWriter, r *http.Request) error {
+	params := MfaVerifyParams{}
+	if err := decodeParams(w, r, &params); err != nil {
+		return err
+	}
+
+	tokenHash := auth.HashToken(params.MfaToken)
+
+	challenge, err := cfg.db.GetMfaChallenge(r.Context(), tokenHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return newError(codeInvalidToken, "Invalid MFA token", nil)
+	}
+	if err != nil {
+		return newError(codeInternal, "Couldn't get MFA challenge", err)
+	}
+
+	if challenge.UsedAt.Valid || challenge.ExpiresAt.Before(time.Now()) {
+		return newError(codeInvalidToken, "MFA token expired", nil)
+	}
+
+	user, err := cfg.db.GetUserById(r.Context(), challenge.UserID)
+	if err != nil {
+		return newError(codeInvalidToken, "User not found", err)
+	}
+
+	// Wrong codes count against the same limits as wrong passwords, so that
+	// starting new challenges doesn't give endless guesses.
+	attempt, err := cfg.startLoginAttempt(w, r, accountLoginKey(user.Email))
+	if err != nil {
+		return err
+	}
+	defer cfg.finishLoginAttempt(r, attempt)
+
+	attempts, err := cfg.db.IncrementMfaChallengeAttempts(r.Context(), tokenHash)
+	if err != nil {
+		return newError(codeInternal, "Couldn't update MFA challenge", err)
+	}
+	if attempts > mfaChallengeMaxAttempts {
+		return newError(codeInvalidToken, "Too many attempts, log in again", nil)
+	}
+
+	err = checkSecondFactor(r.Context(), cfg.db, challenge.UserID, params.Code, params.RecoveryCode)
+	if errors.Is(err, errInvalidSecondFactor) {
+		cfg.failLoginAttempt(r, attempt, uuid.NullUUID{UUID: user.ID, Valid: true})
+		return newError(codeInvalidCredentials, "Invalid code", nil)
+	}
+	if err != nil {
+		return newError(codeInternal, "Couldn't check code", err)
+	}
+
+	used, err := cfg.db.UseMfaChallenge(r.Context(), tokenHash)
+	if err != nil {
+		return newError(codeInternal, "Couldn't update MFA challenge", err)
+	}
+	if used == 0 {
+		return newError(codeInvalidToken, "MFA token expired", nil)
+	}
+
+	// The lifetime asked for at login carries over, unless this request asks
+	// for its own.
+	expiresIn := time.Duration(cmp.Or(params.ExpiresInSec, challenge.ExpiresInSeconds)) * time.Second
+	return cfg.respondWithNewSession(w, r, user, challenge.DeviceLabel.String, expiresIn)
+}
+
+// handlerDisableTotp godoc
+//
+//	@Router		/mfa/totp [delete]
+//	@Summary	disable TOTP
+//	@Description	Requires the account password, if it has one, and a current TOTP or recovery code.
+//	@Tags		mfa
+//	@Accept		json
+//	@Produce	json
+//	@Param		Authorization	header	string	true	"Bearer token"
+//	@Param		body	body	DisableTotpParams	true	"Re-authentication"
+//	@Success	204		"No Content"
+//	@Failure	400		{object}	Problem
+//	@Failure	401		{object}	Problem
+//	@Failure	404		{object}	Problem
+//	@Failure	422		{object}	Problem
+//	@Failure	429		{object}	Problem
+//	@Failure	500		{object}	Problem
+//	@Security	BearerAuth
+func (cfg *Config) handlerDisableTotp(w http.ResponseWriter, r *http.Request) error {
+	params := DisableTotpParams{}
+	if err := decodeParams(w, r, &params); err != nil {
+		return err
+	}
+
+	user, err := cfg.db.GetUserById(r.Context(), UserIDFromContext(r.Context()))
+	if err != nil {
+		return newError(codeInternal, "Couldn't get user", err)
+	}
+
+	// A stolen session mustn't give endless guesses at the password or the
+	// code, so they count against the login limits.
+	attempt, err := cfg.startLoginAttempt(w, r, accountLoginKey(user.Email))
+	if err != nil {
+		return err
+	}
+	defer cfg.finishLoginAttempt(r, attempt)
+
+	if user.PasswordHash != auth.NoPassword {
+		err = auth.CheckPassword(params.Password, user.PasswordHash)
+		if err != nil {
+			cfg.failLoginAttempt(r, attempt, uuid.NullUUID{UUID: user.ID, Valid: true})
+			return newError(codeInvalidCredentials, "Incorrect password", err)
+		}
+	}
+
+	totp, err := cfg.db.GetUserTotp(r.Context(), user.ID)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && !totp.ConfirmedAt.Valid) {
+		return newError(codeNotFound, "Two-factor authentication is not enabled", nil)
+	}
+	if err != nil {
+		return newError(codeInternal, "Couldn't get MFA settings", err)
+	}
+
+	err = checkSecondFactor(r.Context(), cfg.db, user.ID, params.Code, params.RecoveryCode)
+	if errors.Is(err, errInvalidSecondFactor) {
+		cfg.failLoginAttempt(r, attempt, uuid.NullUUID{UUID: user.ID, Valid: true})
+		return newError(codeInvalidCredentials, "Invalid code", nil)
+	}
+	if err != nil {
+		return newError(codeInternal, "Couldn't check code", err)
+	}
+
+	err = cfg.withTx(r.Context(), func(q *database.Queries) error {
+		if err := q.DeleteUserTotp(r.Context(), user.ID); err != nil {
+			return err
+		}
+		return q.DeleteRecoveryCodes(r.Context(), user.ID)
+	})
+	if err != nil {
+		return newError(codeInternal, "Couldn't disable two-factor authentication", err)
+	}
+
+	respondWithJSON(w, http.StatusNoContent, nil)
+	return nil
+}
+
+// startMfaChallenge stores a short-lived challenge for a user who passed the
+// first factor and returns its token in plaintext. The device label and the
+// access token lifetime the login asked for are kept with it, for the session
+// started once the challenge is answered.
+func (cfg *Config) startMfaChallenge(ctx context.Context, userID uuid.UUID, deviceLabel string, expiresIn time.Duration) (string, error) {
+	token, err := auth.MakeRefreshToken()
+	if err != nil {
+		return "", err
+	}
+
+	err = cfg.db.CreateMfaChallenge(ctx, database.CreateMfaChallengeParams{
+		TokenHash:        auth.HashToken(token),
+		UserID:           userID,
+		DeviceLabel:      nullString(deviceLabel),
+		ExpiresAt:        time.Now().Add(mfaChallengeLifetime),
+		ExpiresInSeconds: int64(expiresIn / time.Second),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// checkSecondFactor accepts either a recovery code, which is used up, or a
+// TOTP code for a time step that hasn't been used yet.
+func checkSecondFactor(ctx context.Context, q *database.Queries, userID uuid.UUID, code, recoveryCode string) error {
+	if recoveryCode != "" {
+		used, err := q.UseRecoveryCode(ctx, database.UseRecoveryCodeParams{
+			UserID:   userID,
+			CodeHash: auth.HashToken(auth.NormalizeRecoveryCode(recoveryCode)),
+		})
+		if err != nil {
+			return err
+		}
+		if used == 0 {
+			return errInvalidSecondFactor
+		}
+		return nil
+	}
+
+	totp, err := q.GetUserTotp(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errInvalidSecondFactor
+	}
+	if err != nil {
+		return err
+	}
+
+	step, err := auth.ValidateTOTP(totp.Secret, code, time.Now())
+	if errors.Is(err, auth.ErrInvalidTOTP) {
+		return errInvalidSecondFactor
+	}
+	if err != nil {
+		return err
+	}
+
+	used, err := q.UseTotpStep(ctx, database.UseTotpStepParams{
+		UserID:       userID,
+		LastUsedStep: step,
+	})
+	if err != nil {
+		return err
+	}
+	if used == 0 {
+		// The code is right but was already used once.
+		return errInvalidSecondFactor
+	}
+
+	return nil
+}
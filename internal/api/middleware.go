@@ -0,0 +1,283 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"regexp"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/potom-dev/backend/internal/auth"
+)
+
+// Platform roles a user can have. They are unrelated to group roles.
+const (
+	roleUser  = "user"
+	roleAdmin = "admin"
+)
+
+type contextKey int
+
+const (
+	principalContextKey contextKey = iota
+	requestIDContextKey
+)
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	UserID uuid.UUID
+	Roles  []string
+	// SessionID is the session the access token was issued for, or uuid.Nil
+	// if the token doesn't belong to one.
+	SessionID     uuid.UUID
+	EmailVerified bool
+	// ApiKeyID is the API key the caller authenticated with, or uuid.Nil
+	// for access tokens.
+	ApiKeyID uuid.UUID
+	// Scopes limits what an API key may do. Access tokens aren't limited.
+	Scopes []string
+}
+
+func principalFromClaims(claims *auth.Claims) Principal {
+	// A malformed session ID leaves uuid.Nil, meaning no session.
+	sessionID, _ := uuid.Parse(claims.SessionID)
+
+	return Principal{
+		UserID:        claims.UserID,
+		Roles:         claims.Roles,
+		SessionID:     sessionID,
+		EmailVerified: claims.EmailVerified,
+	}
+}
+
+func (p Principal) HasRole(role string) bool {
+	return slices.Contains(p.Roles, role)
+}
+
+// CanAccessUser reports whether the caller may see and manage userID's
+// account. Users can access their own account and admins everyone's.
+func (p Principal) CanAccessUser(userID uuid.UUID) bool {
+	return p.UserID == userID || p.HasRole(roleAdmin)
+}
+
+// HasScope reports whether the caller may use endpoints that need scope.
+func (p Principal) HasScope(scope string) bool {
+	if p.ApiKeyID == uuid.Nil {
+		return true
+	}
+	return slices.Contains(p.Scopes, scope)
+}
+
+func withPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalContextKey, p)
+}
+
+// PrincipalFromContext returns the caller stored by the authentication middleware.
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalContextKey).(Principal)
+	return p, ok
+}
+
+// UserIDFromContext returns the authenticated user ID, or uuid.Nil for anonymous requests.
+func UserIDFromContext(ctx context.Context) uuid.UUID {
+	p, ok := PrincipalFromContext(ctx)
+	if !ok {
+		return uuid.Nil
+	}
+	return p.UserID
+}
+
+func (cfg *Config) middlewareAuthenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, err := auth.GetCredential(r.Header)
+		if err != nil {
+			respondWithProblem(w, r, newError(codeUnauthenticated, "Couldn't get bearer token", err))
+			return
+		}
+
+		authenticate := cfg.authenticateJWT
+		if auth.IsAPIKey(token) {
+			authenticate = cfg.authenticateApiKey
+		}
+
+		p, err := authenticate(r, token)
+		if err != nil {
+			respondWithProblem(w, r, err)
+			return
+		}
+
+		ctx := withPrincipal(r.Context(), p)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// authenticateJWT returns the caller an access token was issued to, or an
+// error if the token isn't valid.
+func (cfg *Config) authenticateJWT(r *http.Request, token string) (Principal, error) {
+	claims, err := auth.ValidateJWT(token, cfg.jwtKeys)
+	if err != nil {
+		return Principal{}, newError(codeInvalidToken, "Couldn't validate JWT", err)
+	}
+
+	var issuedAt time.Time
+	if claims.IssuedAt != nil {
+		issuedAt = claims.IssuedAt.Time
+	}
+	if cfg.denylist.IsRevoked(claims.ID, claims.SessionID, claims.UserID.String(), issuedAt) {
+		return Principal{}, newError(codeInvalidToken, "Token has been revoked", nil)
+	}
+
+	return principalFromClaims(claims), nil
+}
+
+// middlewareRequireScope turns away API keys that weren't granted scope. An
+// empty scope turns away every API key, for routes they can't use at all.
+func (cfg *Config) middlewareRequireScope(scope string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, ok := PrincipalFromContext(r.Context())
+		if !ok {
+			respondWithProblem(w, r, newError(codeUnauthenticated, "Unauthorized", nil))
+			return
+		}
+		if !p.HasScope(scope) {
+			respondWithProblem(w, r, newError(codeInsufficientScope, "API key is not allowed to use this endpoint", nil))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (cfg *Config) middlewareRequireRole(role string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, ok := PrincipalFromContext(r.Context())
+		if !ok {
+			respondWithProblem(w, r, newError(codeUnauthenticated, "Unauthorized", nil))
+			return
+		}
+		if !p.HasRole(role) {
+			respondWithProblem(w, r, newError(codeForbidden, "Forbidden", nil))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// middlewareRequestID gives every request an ID, reusing the one the client
+// or a proxy sent in the X-Request-Id header if it looks sane. The ID is
+// echoed back in the response header and in problems, so a client can quote
+// it when reporting an error.
+func middlewareRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(requestIDHeader)
+		if !requestIDPattern.MatchString(requestID) {
+			requestID = uuid.NewString()
+		}
+
+		w.Header().Set(requestIDHeader, requestID)
+		ctx := context.WithValue(r.Context(), requestIDContextKey, requestID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+const requestIDHeader = "X-Request-Id"
+
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,128}$`)
+
+// RequestIDFromContext returns the ID middlewareRequestID gave the request.
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDContextKey).(string)
+	return requestID
+}
+
+// middlewareClientIP replaces the peer address of a request that came
+// through one of the trusted proxies with the client address the proxies
+// recorded in X-Forwarded-For, so that clientIP sees the client. The header
+// is read from the right, skipping the trusted proxies, since anything to the
+// left of them could have been sent by the client itself.
+func (cfg *Config) middlewareClientIP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !cfg.isTrustedProxy(clientIP(r)) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			if _, err := netip.ParseAddr(hop); err != nil {
+				break
+			}
+			if !cfg.isTrustedProxy(hop) {
+				r.RemoteAddr = net.JoinHostPort(hop, "0")
+				break
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (cfg *Config) isTrustedProxy(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	return slices.ContainsFunc(cfg.trustedProxies, func(p netip.Prefix) bool {
+		return p.Contains(addr)
+	})
+}
+
+// middlewareRecover turns a panicking handler into a 500 problem instead of
+// a dropped connection, and logs the stack so the bug can be found.
+func middlewareRecover(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			if v == http.ErrAbortHandler {
+				// The handler meant to abort the response, let net/http do so.
+				panic(v)
+			}
+
+			log.Printf("[%s] Panic serving %s %s: %v\n%s", RequestIDFromContext(r.Context()), r.Method, r.URL.Path, v, debug.Stack())
+			respondWithProblem(w, r, newError(codeInternal, "", fmt.Errorf("panic: %v", v)))
+		}()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// routes registers API handlers on a mux. Every route has to be declared
+// with an access level, so a new endpoint can't silently skip authentication.
+type routes struct {
+	mux *http.ServeMux
+	cfg *Config
+}
+
+func (rt routes) public(pattern string, handler handlerFunc) {
+	rt.mux.Handle(pattern, handler)
+}
+
+// authenticated routes need a signed in user. API keys are refused.
+func (rt routes) authenticated(pattern string, handler handlerFunc) {
+	rt.mux.Handle(pattern, rt.cfg.middlewareAuthenticate(rt.cfg.middlewareRequireScope("", handler)))
+}
+
+// scoped routes need a signed in user or an API key granted scope.
+func (rt routes) scoped(pattern string, scope string, handler handlerFunc) {
+	rt.mux.Handle(pattern, rt.cfg.middlewareAuthenticate(rt.cfg.middlewareRequireScope(scope, handler)))
+}
+
+func (rt routes) admin(pattern string, handler handlerFunc) {
+	rt.mux.Handle(pattern, rt.cfg.middlewareAuthenticate(rt.cfg.middlewareRequireScope("", rt.cfg.middlewareRequireRole(roleAdmin, handler))))
+}
@@ -0,0 +1,418 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"log"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/markbates/goth"
+	"github.com/markbates/goth/gothic"
+	"github.com/potom-dev/backend/internal/auth"
+	"github.com/potom-dev/backend/internal/database"
+	"github.com/potom-dev/backend/internal/validation"
+)
+
+const (
+	oauthCodeLifetime = time.Minute * 10
+
+	oauthCodePurposeLogin = "login"
+	oauthCodePurposeLink  = "link"
+
+	// oauthLinkStatePrefix marks an OAuth state that carries a link code
+	// rather than a random nonce.
+	oauthLinkStatePrefix = "link."
+
+	// oauthLinkCookie holds the link code in the browser of the user that
+	// requested it. The state alone only proves that one browser started and
+	// finished the flow: a link URL sent to someone else would attach their
+	// identity to the account that made the code. So the flow only links
+	// when the cookie came along, and the code never comes from the URL.
+	oauthLinkCookie = "oauth_link"
+)
+
+var (
+	errOauthCodeInvalid         = errors.New("oauth code is invalid or expired")
+	errOauthEmailUnverified     = errors.New("provider did not verify the email address")
+	errOauthAccountExists       = errors.New("an unverified account already uses this email")
+	errIdentityInUse            = errors.New("identity is linked to another user")
+	errIdentityProviderAssigned = errors.New("user already has an identity with this provider")
+)
+
+type OauthProvider struct {
+	Name    string `json:"name"`
+	AuthUrl string `json:"auth_url"`
+}
+
+type OauthExchangeParams struct {
+	Code         string `json:"code"`
+	DeviceLabel  string `json:"device_label,omitempty"`
+	ExpiresInSec int64  `json:"expires_in_seconds,omitempty"`
+}
+
+func (p OauthExchangeParams) Validate(v *validation.Validator) {
+	v.Required("code", p.Code)
+	v.MaxLength("device_label", p.DeviceLabel, maxDeviceLabelLength)
+	v.NonNegative("expires_in_seconds", p.ExpiresInSec)
+}
+
+// handlerGetOauthProviders godoc
+//
+//	@Router		/auth/providers [get]
+//	@Summary	list the enabled OAuth providers
+//	@Tags		auth
+//	@Accept		json
+//	@Produce	json
+//	@Success	200	{array}	OauthProvider
+func (cfg *Config) handlerGetOauthProviders(w http.ResponseWriter, r *http.Request) error {
+	providersResponse := []OauthProvider{}
+
+	for _, name := range slices.Sorted(maps.Keys(goth.GetProviders())) {
+		providersResponse = append(providersResponse, OauthProvider{
+			Name:    name,
+			AuthUrl: "/api/auth/" + url.PathEscape(name),
+		})
+	}
+
+	respondWithJSON(w, http.StatusOK, providersResponse)
+	return nil
+}
+
+// handlerOauthAuth sends the browser to the provider. With link=true, it
+// links the provider to the account whose link code is in the link cookie
+// instead of signing in.
+func (cfg *Config) handlerOauthAuth(w http.ResponseWriter, r *http.Request) error {
+	query := r.URL.Query()
+	query.Del("state")
+	if query.Get("link") == "true" {
+		cookie, err := r.Cookie(oauthLinkCookie)
+		if err != nil {
+			cfg.redirectOauthError(w, r, "invalid_link_code", nil)
+			return nil
+		}
+		query.Set("state", oauthLinkStatePrefix+cookie.Value)
+	}
+	query.Del("link")
+	r.URL.RawQuery = query.Encode()
+
+	gothic.BeginAuthHandler(w, r)
+	return nil
+}
+
+// handlerOauthCallback finishes the provider flow and sends the browser back
+// to the app. Tokens never appear in the redirect: a sign in gets a one-time
+// code to trade in at /auth/exchange, and linking needs no tokens at all.
+func (cfg *Config) handlerOauthCallback(w http.ResponseWriter, r *http.Request) error {
+	linkCode, linking := strings.CutPrefix(gothic.GetState(r), oauthLinkStatePrefix)
+	if linking {
+		cookie, err := r.Cookie(oauthLinkCookie)
+		cfg.clearLinkCookie(w)
+		if err != nil || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(linkCode)) != 1 {
+			cfg.redirectOauthError(w, r, "invalid_link_code", nil)
+			return nil
+		}
+	}
+
+	gothUser, err := gothic.CompleteUserAuth(w, r)
+	if err != nil {
+		cfg.redirectOauthError(w, r, "authentication_failed", err)
+		return nil
+	}
+
+	var user database.User
+	err = cfg.withTx(r.Context(), func(q *database.Queries) error {
+		var err error
+		if linking {
+			user, err = linkOauthIdentity(r.Context(), q, linkCode, gothUser)
+		} else {
+			user, err = findOrCreateOauthUser(r.Context(), q, gothUser)
+		}
+		return err
+	})
+	switch {
+	case errors.Is(err, errOauthCodeInvalid):
+		cfg.redirectOauthError(w, r, "invalid_link_code", nil)
+		return nil
+	case errors.Is(err, errOauthEmailUnverified):
+		cfg.redirectOauthError(w, r, "email_not_verified", nil)
+		return nil
+	case errors.Is(err, errOauthAccountExists):
+		cfg.redirectOauthError(w, r, "account_exists", nil)
+		return nil
+	case errors.Is(err, errIdentityInUse):
+		cfg.redirectOauthError(w, r, "identity_in_use", nil)
+		return nil
+	case errors.Is(err, errIdentityProviderAssigned):
+		cfg.redirectOauthError(w, r, "provider_already_linked", nil)
+		return nil
+	case err != nil:
+		cfg.redirectOauthError(w, r, "server_error", err)
+		return nil
+	}
+
+	if linking {
+		cfg.redirectOauth(w, r, url.Values{"linked": {gothUser.Provider}})
+		return nil
+	}
+
+	code, err := cfg.createOauthCode(r.Context(), user.ID, oauthCodePurposeLogin)
+	if err != nil {
+		cfg.redirectOauthError(w, r, "server_error", err)
+		return nil
+	}
+
+	cfg.redirectOauth(w, r, url.Values{"code": {code}})
+	return nil
+}
+
+func (cfg *Config) handlerOauthLogout(w http.ResponseWriter, r *http.Request) error {
+	gothic.Logout(w, r)
+	w.Header().Set("Location", "/")
+	http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
+	return nil
+}
+
+// handlerOauthExchange godoc
+//
+//	@Router		/auth/exchange [post]
+//	@Summary	exchange a one-time OAuth code for tokens
+//	@Tags		auth
+//	@Accept		json
+//	@Produce	json
+//	@Param		body	body		OauthExchangeParams	true	"Code from the OAuth callback redirect"
+//	@Success	200		{object}	LoginResponse
+//	@Success	202		{object}	MfaChallengeResponse
+//	@Failure	400		{object}	Problem
+//	@Failure	401		{object}	Problem
+//	@Failure	403		{object}	Problem
+//	@Failure	422		{object}	Problem
+//	@Failure	500		{object}	Problem
+func (cfg *Config) handlerOauthExchange(w http.ResponseWriter, r *http.Request) error {
+	params := OauthExchangeParams{}
+	if err := decodeParams(w, r, &params); err != nil {
+		return err
+	}
+
+	code, err := cfg.db.UseOauthCode(r.Context(), database.UseOauthCodeParams{
+		CodeHash: auth.HashToken(params.Code),
+		Purpose:  oauthCodePurposeLogin,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return newError(codeInvalidCredentials, "Invalid code", nil)
+	}
+	if err != nil {
+		return newError(codeInternal, "Couldn't use code", err)
+	}
+
+	if code.ExpiresAt.Before(time.Now()) {
+		return newError(codeInvalidToken, "Code expired", nil)
+	}
+
+	user, err := cfg.db.GetUserById(r.Context(), code.UserID)
+	if err != nil {
+		return newError(codeInvalidToken, "User not found", err)
+	}
+
+	return cfg.completeLogin(w, r, user, params.DeviceLabel, time.Duration(params.ExpiresInSec)*time.Second)
+}
+
+// createOauthCode stores a short-lived single use code for the given purpose
+// and returns it in plaintext.
+func (cfg *Config) createOauthCode(ctx context.Context, userID uuid.UUID, purpose string) (string, error) {
+	code, err := auth.MakeRefreshToken()
+	if err != nil {
+		return "", err
+	}
+
+	err = cfg.db.CreateOauthCode(ctx, database.CreateOauthCodeParams{
+		CodeHash:  auth.HashToken(code),
+		UserID:    userID,
+		Purpose:   purpose,
+		ExpiresAt: time.Now().Add(oauthCodeLifetime),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return code, nil
+}
+
+// setLinkCookie stores code in the browser that asked to link provider. Apple
+// posts its callback from its own origin, which browsers only send cookies
+// along with when they are SameSite=None.
+func (cfg *Config) setLinkCookie(w http.ResponseWriter, provider, code string) {
+	cookie := &http.Cookie{
+		Name:     oauthLinkCookie,
+		Value:    code,
+		Path:     "/api/auth/",
+		MaxAge:   int(oauthCodeLifetime.Seconds()),
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(cfg.apiURL, "https://"),
+		SameSite: http.SameSiteLaxMode,
+	}
+	if provider == "apple" {
+		cookie.SameSite = http.SameSiteNoneMode
+		cookie.Secure = true
+	}
+	http.SetCookie(w, cookie)
+}
+
+func (cfg *Config) clearLinkCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     oauthLinkCookie,
+		Path:     "/api/auth/",
+		MaxAge:   -1,
+		HttpOnly: true,
+	})
+}
+
+func (cfg *Config) redirectOauth(w http.ResponseWriter, r *http.Request, query url.Values) {
+	http.Redirect(w, r, cfg.appURL+"/oauth/callback?"+query.Encode(), http.StatusTemporaryRedirect)
+}
+
+func (cfg *Config) redirectOauthError(w http.ResponseWriter, r *http.Request, reason string, err error) {
+	if err != nil {
+		log.Printf("OAuth callback failed: %v", err)
+	}
+	cfg.redirectOauth(w, r, url.Values{"error": {reason}})
+}
+
+// findOrCreateOauthUser returns the user a provider identity signs in as.
+// Unknown identities are attached to the account with the same email, as long
+// as both the provider and our own records have verified that address, or
+// get a new passwordless account.
+func findOrCreateOauthUser(ctx context.Context, q *database.Queries, gothUser goth.User) (database.User, error) {
+	identity, err := q.GetUserIdentity(ctx, database.GetUserIdentityParams{
+		Provider:       gothUser.Provider,
+		ProviderUserID: gothUser.UserID,
+	})
+	if err == nil {
+		return q.GetUserById(ctx, identity.UserID)
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return database.User{}, err
+	}
+
+	if !oauthEmailVerified(gothUser) {
+		return database.User{}, errOauthEmailUnverified
+	}
+
+	user, err := q.GetUserByEmail(ctx, gothUser.Email)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		user, err = q.CreateUserWithoutPassword(ctx, database.CreateUserWithoutPasswordParams{
+			Email:           gothUser.Email,
+			EmailVerifiedAt: sql.NullTime{Time: time.Now(), Valid: true},
+		})
+		if err != nil {
+			return database.User{}, err
+		}
+	case err != nil:
+		return database.User{}, err
+	case !user.EmailVerifiedAt.Valid:
+		// Whoever registered this address never proved they own it, so
+		// handing the account to the provider's user could leave a
+		// stranger holding its password.
+		return database.User{}, errOauthAccountExists
+	}
+
+	_, err = q.CreateUserIdentity(ctx, identityParams(user.ID, gothUser))
+	if err != nil {
+		return database.User{}, err
+	}
+
+	return user, nil
+}
+
+// linkOauthIdentity attaches a provider identity to the user that requested
+// the link code. The caller has checked that the code came from the link
+// cookie of that user's browser.
+func linkOauthIdentity(ctx context.Context, q *database.Queries, linkCode string, gothUser goth.User) (database.User, error) {
+	code, err := q.UseOauthCode(ctx, database.UseOauthCodeParams{
+		CodeHash: auth.HashToken(linkCode),
+		Purpose:  oauthCodePurposeLink,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.User{}, errOauthCodeInvalid
+	}
+	if err != nil {
+		return database.User{}, err
+	}
+	if code.ExpiresAt.Before(time.Now()) {
+		return database.User{}, errOauthCodeInvalid
+	}
+
+	identity, err := q.GetUserIdentity(ctx, database.GetUserIdentityParams{
+		Provider:       gothUser.Provider,
+		ProviderUserID: gothUser.UserID,
+	})
+	if err == nil {
+		if identity.UserID != code.UserID {
+			return database.User{}, errIdentityInUse
+		}
+		return q.GetUserById(ctx, code.UserID)
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return database.User{}, err
+	}
+
+	identities, err := q.GetUserIdentities(ctx, code.UserID)
+	if err != nil {
+		return database.User{}, err
+	}
+	for _, identity := range identities {
+		if identity.Provider == gothUser.Provider {
+			return database.User{}, errIdentityProviderAssigned
+		}
+	}
+
+	_, err = q.CreateUserIdentity(ctx, identityParams(code.UserID, gothUser))
+	if err != nil {
+		return database.User{}, err
+	}
+
+	return q.GetUserById(ctx, code.UserID)
+}
+
+func identityParams(userID uuid.UUID, gothUser goth.User) database.CreateUserIdentityParams {
+	return database.CreateUserIdentityParams{
+		UserID:         userID,
+		Provider:       gothUser.Provider,
+		ProviderUserID: gothUser.UserID,
+		Email:          nullString(gothUser.Email),
+	}
+}
+
+// oauthEmailVerified reports whether the provider vouches for the user's
+// email address. Google's userinfo calls it verified_email, OpenID Connect
+// providers email_verified. GitHub only ever hands out verified addresses and
+// Apple's are verified by definition.
+func oauthEmailVerified(gothUser goth.User) bool {
+	if gothUser.Email == "" {
+		return false
+	}
+
+	switch gothUser.Provider {
+	case "github", "apple":
+		return true
+	}
+
+	for _, key := range []string{"email_verified", "verified_email"} {
+		switch verified := gothUser.RawData[key].(type) {
+		case bool:
+			return verified
+		case string:
+			return verified == "true"
+		}
+	}
+
+	return false
+}
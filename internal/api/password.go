@@ -0,0 +1,183 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/potom-dev/backend/internal/auth"
+	"github.com/potom-dev/backend/internal/database"
+	"github.com/potom-dev/backend/internal/denylist"
+	"github.com/potom-dev/backend/internal/validation"
+)
+
+const passwordResetLifetime = time.Hour
+
+var errPasswordResetTokenExpired = errors.New("password reset token expired")
+
+type ForgotPasswordParams struct {
+	Email string `json:"email"`
+}
+
+func (p ForgotPasswordParams) Validate(v *validation.Validator) {
+	v.Email("email", p.Email)
+}
+
+type ResetPasswordParams struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+func (p ResetPasswordParams) Validate(v *validation.Validator) {
+	v.Required("token", p.Token)
+	v.Password("password", p.Password, "")
+}
+
+// handlerForgotPassword godoc
+//
+//	@Router		/password/forgot [post]
+//	@Summary	email a password reset link
+//	@Tags		auth
+//	@Accept		json
+//	@Produce	json
+//	@Param		body	body	ForgotPasswordParams	true	"Account email"
+//	@Success	202		"Accepted"
+//	@Failure	400		{object}	Problem
+//	@Failure	422		{object}	Problem
+//	@Failure	429		{object}	Problem
+//	@Failure	500		{object}	Problem
+func (cfg *Config) handlerForgotPassword(w http.ResponseWriter, r *http.Request) error {
+	params := ForgotPasswordParams{}
+	if err := decodeParams(w, r, &params); err != nil {
+		return err
+	}
+
+	if err := cfg.limitMailRequest(w, r, params.Email); err != nil {
+		return err
+	}
+
+	// The response is the same whether or not the account exists, so this
+	// endpoint can't be used to find out who is registered.
+	user, err := cfg.db.GetUserByEmail(r.Context(), params.Email)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithJSON(w, http.StatusAccepted, nil)
+		return nil
+	}
+	if err != nil {
+		return newError(codeInternal, "Couldn't get user", err)
+	}
+
+	if err := cfg.sendPasswordReset(r.Context(), user.ID, user.Email); err != nil {
+		return newError(codeInternal, "Couldn't create reset token", err)
+	}
+
+	respondWithJSON(w, http.StatusAccepted, nil)
+	return nil
+}
+
+// revokePasswordCredentials deletes the API keys of a user whose password is
+// being changed and revokes their sessions, except keepSession if it is
+// valid, since whoever knew the old password may still hold any of them. It
+// must run in the transaction that changes the password. It returns the
+// sessions it revoked when it kept one; otherwise every access token of the
+// user has to be revoked once the transaction commits.
+func revokePasswordCredentials(ctx context.Context, q *database.Queries, userID uuid.UUID, keepSession uuid.NullUUID) ([]uuid.UUID, error) {
+	if err := q.DeleteApiKeysForUser(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	if !keepSession.Valid {
+		return nil, q.RevokeAllRefreshTokensForUser(ctx, userID)
+	}
+	return q.RevokeOtherSessions(ctx, database.RevokeOtherSessionsParams{
+		UserID:   userID,
+		FamilyID: keepSession.UUID,
+	})
+}
+
+// sendPasswordReset creates a password reset token for the user and emails
+// them a link to choose a new password.
+func (cfg *Config) sendPasswordReset(ctx context.Context, userID uuid.UUID, email string) error {
+	token, err := auth.MakeRefreshToken()
+	if err != nil {
+		return err
+	}
+
+	err = cfg.db.CreatePasswordResetToken(ctx, database.CreatePasswordResetTokenParams{
+		TokenHash: auth.HashToken(token),
+		UserID:    userID,
+		ExpiresAt: time.Now().Add(passwordResetLifetime),
+	})
+	if err != nil {
+		return err
+	}
+
+	cfg.sendMail(passwordResetEmail(email, cfg.appLink("/reset-password", token), passwordResetLifetime))
+	return nil
+}
+
+// handlerResetPassword godoc
+//
+//	@Router		/password/reset [post]
+//	@Summary	set a new password with a reset token
+//	@Tags		auth
+//	@Accept		json
+//	@Produce	json
+//	@Param		body	body	ResetPasswordParams	true	"Reset token and new password"
+//	@Success	204		"No Content"
+//	@Failure	400		{object}	Problem
+//	@Failure	422		{object}	Problem
+//	@Failure	500		{object}	Problem
+func (cfg *Config) handlerResetPassword(w http.ResponseWriter, r *http.Request) error {
+	params := ResetPasswordParams{}
+	if err := decodeParams(w, r, &params); err != nil {
+		return err
+	}
+
+	pswdHash, err := auth.HashPassword(params.Password)
+	if err != nil {
+		return newError(codeInternal, "Couldn't hash password", err)
+	}
+
+	var userID uuid.UUID
+	err = cfg.withTx(r.Context(), func(q *database.Queries) error {
+		resetToken, err := q.UsePasswordResetToken(r.Context(), auth.HashToken(params.Token))
+		if err != nil {
+			return err
+		}
+		if resetToken.ExpiresAt.Before(time.Now()) {
+			return errPasswordResetTokenExpired
+		}
+		userID = resetToken.UserID
+
+		err = q.UpdateUserPassword(r.Context(), database.UpdateUserPasswordParams{
+			ID:           resetToken.UserID,
+			PasswordHash: pswdHash,
+		})
+		if err != nil {
+			return err
+		}
+
+		err = q.InvalidatePasswordResetTokensForUser(r.Context(), resetToken.UserID)
+		if err != nil {
+			return err
+		}
+
+		_, err = revokePasswordCredentials(r.Context(), q, resetToken.UserID, uuid.NullUUID{})
+		return err
+	})
+	if errors.Is(err, sql.ErrNoRows) || errors.Is(err, errPasswordResetTokenExpired) {
+		return newError(codeInvalidRequest, "Invalid or expired reset token", err)
+	}
+	if err != nil {
+		return newError(codeInternal, "Couldn't reset password", err)
+	}
+
+	cfg.revokeAccessTokens(r.Context(), denylist.KindUser, userID.String())
+
+	respondWithJSON(w, http.StatusNoContent, nil)
+	return nil
+}
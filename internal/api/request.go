@@ -0,0 +1,130 @@
+package api
+
+import (
+	"database/sql"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/potom-dev/backend/internal/pagination"
+	"github.com/potom-dev/backend/internal/validation"
+)
+
+// maxRequestBodyBytes caps the size of JSON request bodies. No request needs
+// anywhere near this much.
+const maxRequestBodyBytes = 1 << 20
+
+// clientIP returns the address of the peer that sent the request.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// parsePage reads the pagination query parameters of a list request. sorts
+// are the accepted sort values, the first being the default.
+func parsePage(r *http.Request, sorts ...string) (pagination.Params, error) {
+	page, err := pagination.Parse(r.URL.Query(), sorts...)
+	switch {
+	case errors.Is(err, pagination.ErrInvalidLimit):
+		return page, newError(codeInvalidRequest, fmt.Sprintf("Limit must be between 1 and %d", pagination.MaxLimit), err)
+	case errors.Is(err, pagination.ErrInvalidSort):
+		return page, newError(codeInvalidRequest, "Sort must be one of "+strings.Join(sorts, ", "), err)
+	case err != nil:
+		return page, newError(codeInvalidRequest, "Invalid cursor", err)
+	}
+	return page, nil
+}
+
+// decodeParams decodes the JSON request body into params, rejecting unknown
+// fields, trailing data and oversized bodies, then checks params' rules if it
+// has any. The error responds with 400 if the body is malformed, 413 if it is
+// too large and 422 if params are invalid.
+func decodeParams(w http.ResponseWriter, r *http.Request, params any) error {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodyBytes)
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+
+	err := decoder.Decode(params)
+	if err == nil && decoder.Decode(&struct{}{}) != io.EOF {
+		err = errTrailingData
+	}
+	if err != nil {
+		return decodeError(err)
+	}
+
+	if v, ok := params.(validation.Validatable); ok {
+		var fields validation.Errors
+		if errors.As(validation.Validate(v), &fields) {
+			e := newError(codeValidationFailed, "Invalid parameters", nil)
+			e.fields = fields
+			return e
+		}
+	}
+	return nil
+}
+
+var errTrailingData = errors.New("request body must contain a single JSON object")
+
+func decodeError(err error) error {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var maxBytesErr *http.MaxBytesError
+
+	switch {
+	case errors.As(err, &maxBytesErr):
+		return newError(codePayloadTooLarge, fmt.Sprintf("Request body must not be larger than %d bytes", maxBytesErr.Limit), err)
+	case errors.Is(err, io.EOF):
+		return newError(codeInvalidRequest, "Request body is required", err)
+	case errors.As(err, &syntaxErr), errors.Is(err, io.ErrUnexpectedEOF):
+		return newError(codeInvalidRequest, "Request body is malformed JSON", err)
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		e := newError(codeInvalidRequest, "Couldn't decode parameters", err)
+		e.fields = validation.Errors{{Field: typeErr.Field, Message: "must be a JSON " + jsonType(typeErr.Type)}}
+		return e
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		// encoding/json has no error type for unknown fields.
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		e := newError(codeInvalidRequest, "Couldn't decode parameters", err)
+		e.fields = validation.Errors{{Field: field, Message: "is not a known field"}}
+		return e
+	case errors.Is(err, errTrailingData):
+		return newError(codeInvalidRequest, "Request body must contain a single JSON object", err)
+	default:
+		return newError(codeInvalidRequest, "Couldn't decode parameters", err)
+	}
+}
+
+// jsonType names the JSON type that decodes into t.
+func jsonType(t reflect.Type) string {
+	if reflect.PointerTo(t).Implements(reflect.TypeFor[encoding.TextUnmarshaler]()) {
+		return "string"
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Struct, reflect.Map:
+		return "object"
+	default:
+		return "string"
+	}
+}
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/potom-dev/backend/internal/validation"
+)
+
+type decodeTestParams struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+func (p decodeTestParams) Validate(v *validation.Validator) {
+	v.Required("name", p.Name)
+}
+
+func TestDecodeParams(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantField  string
+	}{
+		{name: "valid", body: `{"name":"ann","count":2}`},
+		{name: "trailing whitespace", body: "{\"name\":\"ann\"}\n"},
+		{name: "empty", body: "", wantStatus: http.StatusBadRequest},
+		{name: "malformed", body: `{"name":`, wantStatus: http.StatusBadRequest},
+		{name: "unknown field", body: `{"name":"ann","admin":true}`, wantStatus: http.StatusBadRequest, wantField: "admin"},
+		{name: "wrong type", body: `{"name":"ann","count":"two"}`, wantStatus: http.StatusBadRequest, wantField: "count"},
+		{name: "second object", body: `{"name":"ann"}{"name":"bob"}`, wantStatus: http.StatusBadRequest},
+		{name: "trailing garbage", body: `{"name":"ann"} x`, wantStatus: http.StatusBadRequest},
+		{name: "invalid params", body: `{"name":" "}`, wantStatus: http.StatusUnprocessableEntity, wantField: "name"},
+		{
+			name: "at the size limit",
+			body: `{"name":"` + strings.Repeat("a", maxRequestBodyBytes-len(`{"name":""}`)) + `"}`,
+		},
+		{
+			name:       "over the size limit",
+			body:       `{"name":"` + strings.Repeat("a", maxRequestBodyBytes) + `"}`,
+			wantStatus: http.StatusRequestEntityTooLarge,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			params := decodeTestParams{}
+
+			err := decodeParams(httptest.NewRecorder(), r, &params)
+			if tt.wantStatus == 0 {
+				if err != nil {
+					t.Fatalf("decodeParams() error = %v", err)
+				}
+				return
+			}
+
+			var apiErr *apiError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("decodeParams() error = %v, want an apiError", err)
+			}
+			if apiErr.status != tt.wantStatus {
+				t.Errorf("status = %d, want %d", apiErr.status, tt.wantStatus)
+			}
+			if tt.wantField != "" && (len(apiErr.fields) != 1 || apiErr.fields[0].Field != tt.wantField) {
+				t.Errorf("fields = %v, want %s", apiErr.fields, tt.wantField)
+			}
+		})
+	}
+}
@@ -6,23 +6,6 @@ import (
 	"net/http"
 )
 
-type ErrorResponse struct {
-	Error string `json:"error"`
-}
-
-func respondWithError(w http.ResponseWriter, code int, msg string, err error) {
-	if code > 499 {
-		log.Printf("Responding with %d error: %s", code, msg)
-	}
-	if err != nil {
-		log.Printf("Error: %v", err)
-	}
-
-	respondWithJSON(w, code, ErrorResponse{
-		Error: msg,
-	})
-}
-
 func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	dat, err := json.Marshal(payload)
@@ -24,27 +24,86 @@ func NewRouter(cfg *Config) http.Handler {
 	fsHandler := cfg.MiddlewareMetricsInc(http.StripPrefix("/app", http.FileServer(http.Dir("."))))
 	mux.Handle("/app/", fsHandler)
 
-	mux.HandleFunc("GET /api/healthz", cfg.HandlerReadiness)
+	rt := routes{mux: mux, cfg: cfg}
 
-	mux.HandleFunc("POST /api/users", cfg.handlerCreateUser)
-	mux.HandleFunc("GET /api/users", cfg.handlerGetUsers)
-	mux.HandleFunc("GET /api/users/{userId}", cfg.handlerGetUser)
-	mux.HandleFunc("PUT /api/users/{userId}", cfg.handlerUpdateUser)
-	mux.HandleFunc("DELETE /api/users", cfg.handlerDeleteAllUsers)
+	rt.public("GET /api/livez", cfg.handlerLivez)
+	rt.public("GET /api/readyz", cfg.handlerReadyz)
+	// healthz predates livez and is kept for existing probes.
+	rt.public("GET /api/healthz", cfg.handlerLivez)
+	rt.public("GET /.well-known/jwks.json", cfg.handlerJWKS)
 
-	mux.HandleFunc("POST /api/login", cfg.handlerLogin)
-	mux.HandleFunc("POST /api/refresh", cfg.handlerRefresh)
-	mux.HandleFunc("POST /api/revoke", cfg.handlerRevokeRefresh)
+	rt.public("POST /api/users", cfg.handlerCreateUser)
+	rt.admin("GET /api/users", cfg.handlerGetUsers)
+	rt.scoped("GET /api/users/{userId}", scopeUsersRead, cfg.handlerGetUser)
+	rt.scoped("GET /api/users/me", scopeUsersRead, cfg.handlerGetMe)
+	rt.authenticated("PATCH /api/users/me", cfg.handlerUpdateMe)
+	rt.authenticated("POST /api/users/me/password", cfg.handlerChangePassword)
+	rt.authenticated("DELETE /api/users/me", cfg.handlerDeleteMe)
+	rt.authenticated("GET /api/users/me/export", cfg.handlerExportMe)
+	rt.admin("DELETE /api/users", cfg.handlerDeleteAllUsers)
+	rt.public("POST /api/users/verify", cfg.handlerVerifyEmail)
+	rt.authenticated("POST /api/users/verify/resend", cfg.handlerResendEmailVerification)
 
-	mux.HandleFunc("GET /api/auth/{provider}/callback", cfg.handlerOauthCallback)
-	mux.HandleFunc("GET /api/auth/{provider}/logout", cfg.handlerOauthLogout)
-	mux.HandleFunc("GET /api/auth/{provider}", cfg.handlerOauthAuth)
+	rt.authenticated("POST /api/users/me/api-keys", cfg.handlerCreateApiKey)
+	rt.authenticated("GET /api/users/me/api-keys", cfg.handlerGetApiKeys)
+	rt.authenticated("DELETE /api/users/me/api-keys/{keyId}", cfg.handlerRevokeApiKey)
 
-	mux.HandleFunc("POST /api/groups", cfg.handlerCreateGroup)
+	rt.admin("POST /api/users/{userId}/disable", cfg.handlerDisableUser)
+	rt.admin("POST /api/users/{userId}/enable", cfg.handlerEnableUser)
+	rt.admin("POST /api/users/{userId}/restore", cfg.handlerRestoreUser)
+	rt.admin("POST /api/users/{userId}/password-reset", cfg.handlerRequireUserPasswordReset)
+	rt.admin("PUT /api/users/{userId}/role", cfg.handlerUpdateUserRole)
+	rt.admin("DELETE /api/users/{userId}", cfg.handlerDeleteUser)
+
+	rt.public("POST /api/login", cfg.handlerLogin)
+	rt.public("POST /api/refresh", cfg.handlerRefresh)
+	rt.public("POST /api/revoke", cfg.handlerRevokeRefresh)
+
+	rt.public("POST /api/mfa/verify", cfg.handlerVerifyMfa)
+	rt.authenticated("POST /api/mfa/totp/setup", cfg.handlerSetupTotp)
+	rt.authenticated("POST /api/mfa/totp/confirm", cfg.handlerConfirmTotp)
+	rt.authenticated("DELETE /api/mfa/totp", cfg.handlerDisableTotp)
+
+	rt.public("POST /api/password/forgot", cfg.handlerForgotPassword)
+	rt.public("POST /api/password/reset", cfg.handlerResetPassword)
+
+	rt.authenticated("GET /api/sessions", cfg.handlerGetSessions)
+	rt.authenticated("DELETE /api/sessions/{sessionId}", cfg.handlerRevokeSession)
+	rt.authenticated("DELETE /api/sessions", cfg.handlerRevokeOtherSessions)
+
+	rt.public("GET /api/auth/providers", cfg.handlerGetOauthProviders)
+	rt.public("GET /api/auth/{provider}/callback", cfg.handlerOauthCallback)
+	rt.public("POST /api/auth/{provider}/callback", cfg.handlerOauthCallback)
+	rt.public("GET /api/auth/{provider}/logout", cfg.handlerOauthLogout)
+	rt.public("GET /api/auth/{provider}", cfg.handlerOauthAuth)
+	rt.public("POST /api/auth/exchange", cfg.handlerOauthExchange)
+
+	rt.authenticated("GET /api/identities", cfg.handlerGetIdentities)
+	rt.authenticated("POST /api/identities/{provider}/link", cfg.handlerLinkIdentity)
+	rt.authenticated("DELETE /api/identities/{identityId}", cfg.handlerUnlinkIdentity)
+
+	rt.scoped("POST /api/groups", scopeGroupsWrite, cfg.handlerCreateGroup)
+	rt.scoped("GET /api/groups", scopeGroupsRead, cfg.handlerGetGroups)
+	rt.scoped("GET /api/groups/{groupId}", scopeGroupsRead, cfg.handlerGetGroup)
+	rt.scoped("PATCH /api/groups/{groupId}", scopeGroupsWrite, cfg.handlerUpdateGroup)
+	rt.scoped("DELETE /api/groups/{groupId}", scopeGroupsWrite, cfg.handlerDeleteGroup)
+
+	rt.scoped("GET /api/groups/{groupId}/members", scopeGroupsRead, cfg.handlerGetGroupMembers)
+	rt.scoped("POST /api/groups/{groupId}/members", scopeGroupsWrite, cfg.handlerAddGroupMember)
+	rt.scoped("PATCH /api/groups/{groupId}/members/{userId}", scopeGroupsWrite, cfg.handlerUpdateGroupMember)
+	rt.scoped("DELETE /api/groups/{groupId}/members/{userId}", scopeGroupsWrite, cfg.handlerRemoveGroupMember)
+
+	rt.scoped("POST /api/groups/{groupId}/invites", scopeGroupsWrite, cfg.handlerCreateGroupInvite)
+	rt.scoped("GET /api/groups/{groupId}/invites", scopeGroupsRead, cfg.handlerGetGroupInvites)
+	rt.scoped("DELETE /api/groups/{groupId}/invites/{inviteId}", scopeGroupsWrite, cfg.handlerRevokeGroupInvite)
+
+	rt.authenticated("GET /api/invites/{token}", cfg.handlerGetInvite)
+	rt.authenticated("POST /api/invites/{token}/accept", cfg.handlerAcceptInvite)
+	rt.authenticated("POST /api/invites/{token}/decline", cfg.handlerDeclineInvite)
 
 	mux.HandleFunc("/swagger/", httpSwagger.Handler(
 		httpSwagger.URL("http://localhost:8080/swagger/doc.json"), //The url pointing to API definition
 	))
 
-	return mux
+	return middlewareRequestID(cfg.middlewareClientIP(middlewareRecover(mux)))
 }
@@ -0,0 +1,145 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/potom-dev/backend/internal/database"
+	"github.com/potom-dev/backend/internal/denylist"
+	"github.com/potom-dev/backend/internal/pagination"
+)
+
+type Session struct {
+	Id          uuid.UUID `json:"id"`
+	DeviceLabel string    `json:"device_label,omitempty"`
+	UserAgent   string    `json:"user_agent,omitempty"`
+	IpAddress   string    `json:"ip_address,omitempty"`
+	LastUsedAt  time.Time `json:"last_used_at"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// handlerGetSessions godoc
+//
+//	@Router		/sessions [get]
+//	@Summary	list the caller's active sessions
+//	@Description	Newest first.
+//	@Tags		sessions
+//	@Accept		json
+//	@Produce	json
+//	@Param		Authorization	header	string	true	"Bearer token"
+//	@Param		limit	query	int		false	"Page size, 1 to 200"	default(50)
+//	@Param		cursor	query	string	false	"next_cursor of the previous page"
+//	@Success	200	{object}	pagination.Page[Session]
+//	@Failure	400	{object}	Problem
+//	@Failure	401	{object}	Problem
+//	@Failure	500	{object}	Problem
+//	@Security	BearerAuth
+func (cfg *Config) handlerGetSessions(w http.ResponseWriter, r *http.Request) error {
+	page, err := parsePage(r, "-created_at")
+	if err != nil {
+		return err
+	}
+
+	tokens, err := cfg.db.ListActiveSessions(r.Context(), database.ListActiveSessionsParams{
+		UserID:         UserIDFromContext(r.Context()),
+		AfterCreatedAt: page.AfterTime(),
+		AfterID:        page.AfterID(),
+		Limit:          page.FetchLimit(),
+	})
+	if err != nil {
+		return newError(codeInternal, "Couldn't get sessions", err)
+	}
+
+	cursor := func(token database.RefreshToken) pagination.Cursor {
+		return pagination.Cursor{Time: &token.CreatedAt, ID: token.ID}
+	}
+
+	respondWithJSON(w, http.StatusOK, pagination.NewPage(tokens, page, cursor, sessionFromDB))
+	return nil
+}
+
+// handlerRevokeSession godoc
+//
+//	@Router		/sessions/{sessionId} [delete]
+//	@Summary	revoke one of the caller's sessions
+//	@Tags		sessions
+//	@Accept		json
+//	@Produce	json
+//	@Param		Authorization	header	string	true	"Bearer token"
+//	@Param		sessionId	path	string	true	"Session ID"
+//	@Success	204		"No Content"
+//	@Failure	400		{object}	Problem
+//	@Failure	401		{object}	Problem
+//	@Failure	404		{object}	Problem
+//	@Failure	500		{object}	Problem
+//	@Security	BearerAuth
+func (cfg *Config) handlerRevokeSession(w http.ResponseWriter, r *http.Request) error {
+	sessionID, err := uuid.Parse(r.PathValue("sessionId"))
+	if err != nil {
+		return newError(codeInvalidRequest, "Invalid session ID", err)
+	}
+
+	revoked, err := cfg.db.RevokeSession(r.Context(), database.RevokeSessionParams{
+		FamilyID: sessionID,
+		UserID:   UserIDFromContext(r.Context()),
+	})
+	if err != nil {
+		return newError(codeInternal, "Couldn't revoke session", err)
+	}
+	if revoked == 0 {
+		return newError(codeNotFound, "Session not found", nil)
+	}
+
+	cfg.revokeAccessTokens(r.Context(), denylist.KindSession, sessionID.String())
+
+	respondWithJSON(w, http.StatusNoContent, nil)
+	return nil
+}
+
+// handlerRevokeOtherSessions godoc
+//
+//	@Router		/sessions [delete]
+//	@Summary	revoke all of the caller's sessions except the current one
+//	@Tags		sessions
+//	@Accept		json
+//	@Produce	json
+//	@Param		Authorization	header	string	true	"Bearer token"
+//	@Success	204		"No Content"
+//	@Failure	401		{object}	Problem
+//	@Failure	500		{object}	Problem
+//	@Security	BearerAuth
+func (cfg *Config) handlerRevokeOtherSessions(w http.ResponseWriter, r *http.Request) error {
+	p, _ := PrincipalFromContext(r.Context())
+
+	sessionIDs, err := cfg.db.RevokeOtherSessions(r.Context(), database.RevokeOtherSessionsParams{
+		UserID:   p.UserID,
+		FamilyID: p.SessionID,
+	})
+	if err != nil {
+		return newError(codeInternal, "Couldn't revoke sessions", err)
+	}
+
+	for _, sessionID := range sessionIDs {
+		cfg.revokeAccessTokens(r.Context(), denylist.KindSession, sessionID.String())
+	}
+
+	respondWithJSON(w, http.StatusNoContent, nil)
+	return nil
+}
+
+func sessionFromDB(token database.RefreshToken) Session {
+	lastUsed := token.CreatedAt
+	if token.LastUsedAt.Valid {
+		lastUsed = token.LastUsedAt.Time
+	}
+
+	return Session{
+		Id:          token.FamilyID,
+		DeviceLabel: token.DeviceLabel.String,
+		UserAgent:   token.UserAgent.String,
+		IpAddress:   token.IpAddress.String,
+		LastUsedAt:  lastUsed,
+		ExpiresAt:   token.ExpiresAt,
+	}
+}
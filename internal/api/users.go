@@ -1,27 +1,125 @@
 package api
 
 import (
-	"encoding/json"
+	"database/sql"
+	"errors"
 	"log"
 	"net/http"
-	"os"
+	"regexp"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/potom-dev/backend/internal/auth"
 	"github.com/potom-dev/backend/internal/database"
+	"github.com/potom-dev/backend/internal/denylist"
+	"github.com/potom-dev/backend/internal/pagination"
+	"github.com/potom-dev/backend/internal/validation"
 )
 
-type CreateUpdateUserParams struct {
+// localePattern loosely matches a BCP 47 language tag such as "en" or
+// "pt-BR".
+var localePattern = regexp.MustCompile(`^[A-Za-z]{2,3}(-[A-Za-z0-9]{2,8})*$`)
+
+const maxDisplayNameLength = 100
+
+type CreateUserParams struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
 }
 
+func (p CreateUserParams) Validate(v *validation.Validator) {
+	v.Email("email", p.Email)
+	v.Password("password", p.Password, p.Email)
+}
+
+// UpdateMeParams holds the fields to change. Omitted fields are left as they
+// are, and an empty string clears a profile field.
+type UpdateMeParams struct {
+	Email       *string `json:"email,omitempty"`
+	DisplayName *string `json:"display_name,omitempty"`
+	AvatarUrl   *string `json:"avatar_url,omitempty"`
+	Locale      *string `json:"locale,omitempty"`
+	Timezone    *string `json:"timezone,omitempty"`
+	// CurrentPassword is required to change the email of an account that
+	// has a password.
+	CurrentPassword string `json:"current_password,omitempty"`
+}
+
+// Validate checks the fields that are set.
+func (p UpdateMeParams) Validate(v *validation.Validator) {
+	if p.Email != nil {
+		v.Email("email", *p.Email)
+	}
+	if p.DisplayName != nil {
+		v.MaxLength("display_name", strings.TrimSpace(*p.DisplayName), maxDisplayNameLength)
+	}
+	if p.AvatarUrl != nil && *p.AvatarUrl != "" {
+		v.URL("avatar_url", *p.AvatarUrl)
+	}
+	if p.Locale != nil && *p.Locale != "" {
+		v.Check(localePattern.MatchString(*p.Locale), "locale", "must be a language tag such as en or pt-BR")
+	}
+	if p.Timezone != nil && *p.Timezone != "" {
+		_, err := time.LoadLocation(*p.Timezone)
+		v.Check(err == nil, "timezone", "must be an IANA time zone such as Europe/Berlin")
+	}
+}
+
+type ChangePasswordParams struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+func (p ChangePasswordParams) Validate(v *validation.Validator) {
+	v.Password("new_password", p.NewPassword, "")
+}
+
 type User struct {
-	Id        uuid.UUID `json:"id"`
-	Email     string    `json:"email"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	Id            uuid.UUID  `json:"id"`
+	Email         string     `json:"email"`
+	Role          string     `json:"role"`
+	EmailVerified bool       `json:"email_verified"`
+	DisplayName   string     `json:"display_name,omitempty"`
+	AvatarUrl     string     `json:"avatar_url,omitempty"`
+	Locale        string     `json:"locale,omitempty"`
+	Timezone      string     `json:"timezone,omitempty"`
+	DisabledAt    *time.Time `json:"disabled_at,omitempty"`
+	DeletedAt     *time.Time `json:"deleted_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+func userFromDB(user database.User) User {
+	resp := User{
+		Id:            user.ID,
+		Email:         user.Email,
+		Role:          user.Role,
+		EmailVerified: user.EmailVerifiedAt.Valid,
+		DisplayName:   user.DisplayName.String,
+		AvatarUrl:     user.AvatarUrl.String,
+		Locale:        user.Locale.String,
+		Timezone:      user.Timezone.String,
+		CreatedAt:     user.CreatedAt,
+		UpdatedAt:     user.UpdatedAt,
+	}
+	if user.DisabledAt.Valid {
+		resp.DisabledAt = &user.DisabledAt.Time
+	}
+	if user.DeletedAt.Valid {
+		resp.DeletedAt = &user.DeletedAt.Time
+	}
+	return resp
+}
+
+// requireUserAccess returns an error unless the caller may see and manage
+// userID's account.
+func requireUserAccess(r *http.Request, userID uuid.UUID) error {
+	p, _ := PrincipalFromContext(r.Context())
+	if !p.CanAccessUser(userID) {
+		return newError(codeForbidden, "Forbidden", nil)
+	}
+	return nil
 }
 
 // handlerCreateUser godoc
@@ -31,179 +129,366 @@ type User struct {
 //	@Tags		users
 //	@Accept		json
 //	@Produce	json
-//	@Param		body	body		CreateUpdateUserParams	true	"User creation parameters"
+//	@Param		body	body		CreateUserParams	true	"User creation parameters"
 //	@Success	201		{object}	User
-//	@Failure	500		{object}	ErrorResponse
-func (cfg *Config) handlerCreateUser(w http.ResponseWriter, r *http.Request) {
-	decoder := json.NewDecoder(r.Body)
-	params := CreateUpdateUserParams{}
-
-	if err := decoder.Decode(&params); err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters", err)
-		return
+//	@Failure	400		{object}	Problem
+//	@Failure	422		{object}	Problem
+//	@Failure	500		{object}	Problem
+func (cfg *Config) handlerCreateUser(w http.ResponseWriter, r *http.Request) error {
+	params := CreateUserParams{}
+	if err := decodeParams(w, r, &params); err != nil {
+		return err
 	}
 
 	pswdHash, err := auth.HashPassword(params.Password)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't hash password", err)
-		return
+		return newError(codeInternal, "Couldn't hash password", err)
 	}
 
-	log.Printf("Hashed password: %s", pswdHash)
-
 	user, err := cfg.db.CreateUser(r.Context(), database.CreateUserParams{
 		Email:        params.Email,
 		PasswordHash: pswdHash,
 	})
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't create user", err)
-		return
+		return newError(codeInternal, "Couldn't create user", err)
 	}
 
-	respondWithJSON(w, http.StatusCreated, User{
-		Id:        user.ID,
-		Email:     user.Email,
-		CreatedAt: user.CreatedAt,
-		UpdatedAt: user.UpdatedAt,
-	})
+	if err := cfg.sendEmailVerification(r.Context(), user.ID, user.Email); err != nil {
+		log.Printf("Error sending verification email to user %s: %v", user.ID, err)
+	}
+
+	respondWithJSON(w, http.StatusCreated, userFromDB(user))
+	return nil
 }
 
 // handlerGetUsers godoc
 //
 //	@Router		/users [get]
-//	@Summary	get all users
+//	@Summary	list users
+//	@Description	Admin only.
 //	@Tags		users
 //	@Accept		json
 //	@Produce	json
-//	@Success	200	{array}		User
-//	@Failure	500	{object}	ErrorResponse
-func (cfg *Config) handlerGetUsers(w http.ResponseWriter, r *http.Request) {
-	users, err := cfg.db.GetUsers(r.Context())
+//	@Param		Authorization	header	string	true	"Bearer token"
+//	@Param		limit			query	int		false	"Page size, 1 to 200"	default(50)
+//	@Param		cursor			query	string	false	"next_cursor of the previous page"
+//	@Param		sort			query	string	false	"Sort order"	Enums(created_at, -created_at, email, -email)	default(created_at)
+//	@Param		email_prefix	query	string	false	"Only users whose email starts with this, ignoring case"
+//	@Param		created_after	query	string	false	"Only users created at or after this RFC 3339 time"
+//	@Param		created_before	query	string	false	"Only users created before this RFC 3339 time"
+//	@Success	200	{object}	pagination.Page[User]
+//	@Failure	400	{object}	Problem
+//	@Failure	401	{object}	Problem
+//	@Failure	403	{object}	Problem
+//	@Failure	500	{object}	Problem
+//	@Security	BearerAuth
+func (cfg *Config) handlerGetUsers(w http.ResponseWriter, r *http.Request) error {
+	page, err := parsePage(r, "created_at", "-created_at", "email", "-email")
+	if err != nil {
+		return err
+	}
+
+	query := r.URL.Query()
+	emailPrefix := pagination.PrefixFilter(query, "email_prefix")
+	createdAfter, err := pagination.TimeFilter(query, "created_after")
+	if err != nil {
+		return newError(codeInvalidRequest, "created_after must be an RFC 3339 time", err)
+	}
+	createdBefore, err := pagination.TimeFilter(query, "created_before")
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't get users", err)
-		return
+		return newError(codeInvalidRequest, "created_before must be an RFC 3339 time", err)
 	}
 
-	usersResponse := []User{}
+	cursor := func(user database.User) pagination.Cursor {
+		return pagination.Cursor{Time: &user.CreatedAt, ID: user.ID}
+	}
 
-	for _, user := range users {
-		usersResponse = append(usersResponse, User{
-			Id:        user.ID,
-			Email:     user.Email,
-			CreatedAt: user.CreatedAt,
-			UpdatedAt: user.UpdatedAt,
+	var users []database.User
+	switch page.Sort {
+	case "created_at":
+		users, err = cfg.db.ListUsersByCreatedAt(r.Context(), database.ListUsersByCreatedAtParams{
+			EmailPrefix:    emailPrefix,
+			CreatedAfter:   createdAfter,
+			CreatedBefore:  createdBefore,
+			AfterCreatedAt: page.AfterTime(),
+			AfterID:        page.AfterID(),
+			Limit:          page.FetchLimit(),
+		})
+	case "-created_at":
+		users, err = cfg.db.ListUsersByCreatedAtDesc(r.Context(), database.ListUsersByCreatedAtDescParams{
+			EmailPrefix:    emailPrefix,
+			CreatedAfter:   createdAfter,
+			CreatedBefore:  createdBefore,
+			AfterCreatedAt: page.AfterTime(),
+			AfterID:        page.AfterID(),
+			Limit:          page.FetchLimit(),
+		})
+	case "email":
+		users, err = cfg.db.ListUsersByEmail(r.Context(), database.ListUsersByEmailParams{
+			EmailPrefix:   emailPrefix,
+			CreatedAfter:  createdAfter,
+			CreatedBefore: createdBefore,
+			AfterEmail:    page.AfterValue(),
+			Limit:         page.FetchLimit(),
 		})
+	case "-email":
+		users, err = cfg.db.ListUsersByEmailDesc(r.Context(), database.ListUsersByEmailDescParams{
+			EmailPrefix:   emailPrefix,
+			CreatedAfter:  createdAfter,
+			CreatedBefore: createdBefore,
+			AfterEmail:    page.AfterValue(),
+			Limit:         page.FetchLimit(),
+		})
+	}
+	if err != nil {
+		return newError(codeInternal, "Couldn't get users", err)
 	}
 
-	respondWithJSON(w, http.StatusOK, usersResponse)
+	if page.Sort == "email" || page.Sort == "-email" {
+		cursor = func(user database.User) pagination.Cursor {
+			return pagination.Cursor{Value: user.Email, ID: user.ID}
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, pagination.NewPage(users, page, cursor, userFromDB))
+	return nil
 }
 
 // handlerGetUser godoc
 //
 //	@Router		/users/{userId} [get]
 //	@Summary	get user by id
+//	@Description	Users can only get their own account, admins can get anyone's.
 //	@Tags		users
 //	@Accept		json
 //	@Produce	json
+//	@Param		Authorization	header	string	true	"Bearer token"
 //	@Param		userId	path		string	true	"User ID"
 //	@Success	200		{object}	User
-//	@Failure	500		{object}	ErrorResponse
-func (cfg *Config) handlerGetUser(w http.ResponseWriter, r *http.Request) {
-	userId := r.PathValue("userId")
-	user, err := cfg.db.GetUserById(r.Context(), uuid.MustParse(userId))
+//	@Failure	400		{object}	Problem
+//	@Failure	401		{object}	Problem
+//	@Failure	403		{object}	Problem
+//	@Failure	404		{object}	Problem
+//	@Failure	500		{object}	Problem
+//	@Security	BearerAuth
+func (cfg *Config) handlerGetUser(w http.ResponseWriter, r *http.Request) error {
+	userID, err := uuid.Parse(r.PathValue("userId"))
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't get user", err)
-		return
+		return newError(codeInvalidRequest, "Invalid user ID", err)
 	}
-	respondWithJSON(w, http.StatusOK, user)
+
+	if err := requireUserAccess(r, userID); err != nil {
+		return err
+	}
+
+	user, err := cfg.db.GetUserById(r.Context(), userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return newError(codeNotFound, "User not found", err)
+	}
+	if err != nil {
+		return newError(codeInternal, "Couldn't get user", err)
+	}
+
+	respondWithJSON(w, http.StatusOK, userFromDB(user))
+	return nil
 }
 
-// handlerUpdateUser godoc
+// handlerGetMe godoc
 //
-//	@Router		/users/{userId} [put]
-//	@Summary	update user
+//	@Router		/users/me [get]
+//	@Summary	get the caller's account
 //	@Tags		users
 //	@Accept		json
 //	@Produce	json
-//	@Param		userId	path	string				true	"User ID"
-//	@Param		body	body	CreateUpdateUserParams	true	"User update data"
-//	@Success	204		"No Content"
-//	@Failure	401		{object}	ErrorResponse
-//	@Failure	403		{object}	ErrorResponse
-//	@Failure	404		{object}	ErrorResponse
-//	@Failure	500		{object}	ErrorResponse
+//	@Param		Authorization	header	string	true	"Bearer token"
+//	@Success	200	{object}	User
+//	@Failure	401	{object}	Problem
+//	@Failure	500	{object}	Problem
 //	@Security	BearerAuth
-func (cfg *Config) handlerUpdateUser(w http.ResponseWriter, r *http.Request) {
-	userId := r.PathValue("userId")
-
-	token, err := auth.GetBearerToken(r.Header)
+func (cfg *Config) handlerGetMe(w http.ResponseWriter, r *http.Request) error {
+	user, err := cfg.db.GetUserById(r.Context(), UserIDFromContext(r.Context()))
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't get bearer token", err)
-		return
+		return newError(codeInternal, "Couldn't get user", err)
+	}
+
+	respondWithJSON(w, http.StatusOK, userFromDB(user))
+	return nil
+}
+
+// handlerUpdateMe godoc
+//
+//	@Router		/users/me [patch]
+//	@Summary	update the caller's email and profile
+//	@Description	Only the fields present in the body are changed. Changing the email address requires the current password, if the account has one. The new address gets a verification email and replaces the old one once it is verified.
+//	@Tags		users
+//	@Accept		json
+//	@Produce	json
+//	@Param		Authorization	header	string	true	"Bearer token"
+//	@Param		body	body		UpdateMeParams	true	"Fields to update"
+//	@Success	200		{object}	User
+//	@Failure	400		{object}	Problem
+//	@Failure	401		{object}	Problem
+//	@Failure	409		{object}	Problem
+//	@Failure	422		{object}	Problem
+//	@Failure	500		{object}	Problem
+//	@Security	BearerAuth
+func (cfg *Config) handlerUpdateMe(w http.ResponseWriter, r *http.Request) error {
+	params := UpdateMeParams{}
+	if err := decodeParams(w, r, &params); err != nil {
+		return err
 	}
 
-	authedUserID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	user, err := cfg.db.GetUserById(r.Context(), UserIDFromContext(r.Context()))
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
-		return
+		return newError(codeInternal, "Couldn't get user", err)
+	}
+
+	update := database.UpdateUserProfileParams{
+		ID:              user.ID,
+		Email:           user.Email,
+		EmailVerifiedAt: user.EmailVerifiedAt,
+		DisplayName:     user.DisplayName,
+		AvatarUrl:       user.AvatarUrl,
+		Locale:          user.Locale,
+		Timezone:        user.Timezone,
 	}
 
-	if authedUserID != uuid.MustParse(userId) {
-		respondWithError(w, http.StatusForbidden, "Forbidden", nil)
-		return
+	emailChanged := params.Email != nil && *params.Email != user.Email
+	if emailChanged {
+		if user.PasswordHash != auth.NoPassword {
+			err = auth.CheckPassword(params.CurrentPassword, user.PasswordHash)
+			if err != nil {
+				return newError(codeInvalidCredentials, "Incorrect password", err)
+			}
+		}
+
+		_, err := cfg.db.GetUserByEmail(r.Context(), *params.Email)
+		if err == nil {
+			return newError(codeConflict, "Email is already in use", nil)
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return newError(codeInternal, "Couldn't check email", err)
+		}
+	}
+	if params.DisplayName != nil {
+		update.DisplayName = nullString(strings.TrimSpace(*params.DisplayName))
+	}
+	if params.AvatarUrl != nil {
+		update.AvatarUrl = nullString(*params.AvatarUrl)
+	}
+	if params.Locale != nil {
+		update.Locale = nullString(*params.Locale)
+	}
+	if params.Timezone != nil {
+		update.Timezone = nullString(*params.Timezone)
 	}
 
-	user, err := cfg.db.GetUserById(r.Context(), uuid.MustParse(userId))
+	user, err = cfg.db.UpdateUserProfile(r.Context(), update)
 	if err != nil {
-		respondWithError(w, http.StatusNotFound, "User not found", err)
-		return
+		return newError(codeInternal, "Couldn't update user", err)
 	}
 
-	decoder := json.NewDecoder(r.Body)
-	params := CreateUpdateUserParams{}
+	// The account keeps its address until the new one is verified, so that
+	// a stolen session can't send password resets to another inbox.
+	if emailChanged {
+		if err := cfg.startEmailChange(r.Context(), user.ID, *params.Email); err != nil {
+			return newError(codeInternal, "Couldn't send verification email", err)
+		}
+	}
 
-	if err := decoder.Decode(&params); err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters", err)
-		return
+	respondWithJSON(w, http.StatusOK, userFromDB(user))
+	return nil
+}
+
+// handlerChangePassword godoc
+//
+//	@Router		/users/me/password [post]
+//	@Summary	change the caller's password
+//	@Description	Requires the current password, unless the account doesn't have one yet. Signs out every other session and deletes the API keys.
+//	@Tags		users
+//	@Accept		json
+//	@Produce	json
+//	@Param		Authorization	header	string	true	"Bearer token"
+//	@Param		body	body	ChangePasswordParams	true	"Current and new password"
+//	@Success	204		"No Content"
+//	@Failure	400		{object}	Problem
+//	@Failure	401		{object}	Problem
+//	@Failure	422		{object}	Problem
+//	@Failure	500		{object}	Problem
+//	@Security	BearerAuth
+func (cfg *Config) handlerChangePassword(w http.ResponseWriter, r *http.Request) error {
+	params := ChangePasswordParams{}
+	if err := decodeParams(w, r, &params); err != nil {
+		return err
 	}
 
-	pswdHash, err := auth.HashPassword(params.Password)
+	p, _ := PrincipalFromContext(r.Context())
+
+	user, err := cfg.db.GetUserById(r.Context(), p.UserID)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't hash password", err)
-		return
+		return newError(codeInternal, "Couldn't get user", err)
 	}
 
-	err = cfg.db.UpdateUser(r.Context(), database.UpdateUserParams{
-		ID:           user.ID,
-		Email:        params.Email,
-		PasswordHash: pswdHash,
+	if user.PasswordHash != auth.NoPassword {
+		err = auth.CheckPassword(params.CurrentPassword, user.PasswordHash)
+		if err != nil {
+			return newError(codeInvalidCredentials, "Incorrect password", err)
+		}
+	}
+
+	pswdHash, err := auth.HashPassword(params.NewPassword)
+	if err != nil {
+		return newError(codeInternal, "Couldn't hash password", err)
+	}
+
+	var sessionIDs []uuid.UUID
+	err = cfg.withTx(r.Context(), func(q *database.Queries) error {
+		err := q.UpdateUserPassword(r.Context(), database.UpdateUserPasswordParams{
+			ID:           user.ID,
+			PasswordHash: pswdHash,
+		})
+		if err != nil {
+			return err
+		}
+
+		sessionIDs, err = revokePasswordCredentials(r.Context(), q, user.ID, uuid.NullUUID{UUID: p.SessionID, Valid: true})
+		return err
 	})
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't update user", err)
-		return
+		return newError(codeInternal, "Couldn't change password", err)
+	}
+
+	for _, sessionID := range sessionIDs {
+		cfg.revokeAccessTokens(r.Context(), denylist.KindSession, sessionID.String())
 	}
+
 	respondWithJSON(w, http.StatusNoContent, nil)
+	return nil
 }
 
 // handlerDeleteAllUsers godoc
 //
 //	@Router		/users [delete]
 //	@Summary	delete all users
+//	@Description	Admin only, and only on the dev platform.
 //	@Tags		users
 //	@Accept		json
 //	@Produce	json
+//	@Param		Authorization	header	string	true	"Bearer token"
 //	@Success	200	{string}	string
-//	@Failure	405	{object}	ErrorResponse
-//	@Failure	500	{object}	ErrorResponse
-func (cfg *Config) handlerDeleteAllUsers(w http.ResponseWriter, r *http.Request) {
-	if os.Getenv("PLATFORM") != "dev" {
-		respondWithError(w, http.StatusMethodNotAllowed, "Not allowed", nil)
-		return
+//	@Failure	401	{object}	Problem
+//	@Failure	403	{object}	Problem
+//	@Failure	405	{object}	Problem
+//	@Failure	500	{object}	Problem
+//	@Security	BearerAuth
+func (cfg *Config) handlerDeleteAllUsers(w http.ResponseWriter, r *http.Request) error {
+	if cfg.platform != "dev" {
+		return newError(codeMethodNotAllowed, "Not allowed", nil)
 	}
 	err := cfg.db.DeleteAllUsers(r.Context())
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't delete users", err)
-		return
+		return newError(codeInternal, "Couldn't delete users", err)
 	}
 	respondWithJSON(w, http.StatusOK, "All users deleted")
+	return nil
 }
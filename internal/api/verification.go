@@ -0,0 +1,187 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/potom-dev/backend/internal/auth"
+	"github.com/potom-dev/backend/internal/database"
+	"github.com/potom-dev/backend/internal/validation"
+)
+
+const emailVerificationLifetime = time.Hour * 48
+
+var errEmailVerificationTokenExpired = errors.New("email verification token expired")
+
+type VerifyEmailParams struct {
+	Token string `json:"token"`
+}
+
+func (p VerifyEmailParams) Validate(v *validation.Validator) {
+	v.Required("token", p.Token)
+}
+
+// sendEmailVerification issues a verification token for the given address
+// and emails a link containing it.
+func (cfg *Config) sendEmailVerification(ctx context.Context, userID uuid.UUID, email string) error {
+	token, err := auth.MakeRefreshToken()
+	if err != nil {
+		return err
+	}
+
+	err = cfg.db.CreateEmailVerificationToken(ctx, database.CreateEmailVerificationTokenParams{
+		TokenHash: auth.HashToken(token),
+		UserID:    userID,
+		Email:     email,
+		ExpiresAt: time.Now().Add(emailVerificationLifetime),
+	})
+	if err != nil {
+		return err
+	}
+
+	cfg.sendMail(emailVerificationEmail(email, cfg.appLink("/verify-email", token), emailVerificationLifetime))
+	return nil
+}
+
+// startEmailChange sends a verification link to the address a user wants to
+// change their email to. Links sent earlier stop working, so that only the
+// latest change can go through.
+func (cfg *Config) startEmailChange(ctx context.Context, userID uuid.UUID, email string) error {
+	if err := cfg.db.DeleteEmailVerificationTokensForUser(ctx, userID); err != nil {
+		return err
+	}
+	return cfg.sendEmailVerification(ctx, userID, email)
+}
+
+// requireVerifiedEmail returns an error if the verification policy is
+// enabled and the caller hasn't verified their email.
+func (cfg *Config) requireVerifiedEmail(r *http.Request) error {
+	if !cfg.requireVerifiedEmailForGroups {
+		return nil
+	}
+
+	user, err := cfg.db.GetUserById(r.Context(), UserIDFromContext(r.Context()))
+	if err != nil {
+		return newError(codeInternal, "Couldn't get user", err)
+	}
+
+	if !user.EmailVerifiedAt.Valid {
+		return newError(codeEmailNotVerified, "Email address is not verified", nil)
+	}
+
+	return nil
+}
+
+// handlerVerifyEmail godoc
+//
+//	@Router		/users/verify [post]
+//	@Summary	verify an email address
+//	@Description	A link sent to a new address also changes the account's email to it.
+//	@Tags		users
+//	@Accept		json
+//	@Produce	json
+//	@Param		body	body	VerifyEmailParams	true	"Verification token"
+//	@Success	204		"No Content"
+//	@Failure	400		{object}	Problem
+//	@Failure	409		{object}	Problem
+//	@Failure	422		{object}	Problem
+//	@Failure	500		{object}	Problem
+func (cfg *Config) handlerVerifyEmail(w http.ResponseWriter, r *http.Request) error {
+	params := VerifyEmailParams{}
+	if err := decodeParams(w, r, &params); err != nil {
+		return err
+	}
+
+	var previousEmail, newEmail string
+	err := cfg.withTx(r.Context(), func(q *database.Queries) error {
+		verification, err := q.UseEmailVerificationToken(r.Context(), auth.HashToken(params.Token))
+		if err != nil {
+			return err
+		}
+		if verification.ExpiresAt.Before(time.Now()) {
+			return errEmailVerificationTokenExpired
+		}
+
+		user, err := q.GetUserById(r.Context(), verification.UserID)
+		if err != nil {
+			return err
+		}
+
+		if verification.Email == user.Email {
+			_, err = q.MarkUserEmailVerified(r.Context(), database.MarkUserEmailVerifiedParams{
+				ID:    user.ID,
+				Email: user.Email,
+			})
+			return err
+		}
+
+		// A link sent to another address confirms a change of email.
+		err = q.ChangeUserEmail(r.Context(), database.ChangeUserEmailParams{
+			ID:    user.ID,
+			Email: verification.Email,
+		})
+		if err != nil {
+			return err
+		}
+		previousEmail, newEmail = user.Email, verification.Email
+
+		// Links still out for other addresses mustn't change it again.
+		return q.DeleteEmailVerificationTokensForUser(r.Context(), user.ID)
+	})
+	if errors.Is(err, sql.ErrNoRows) || errors.Is(err, errEmailVerificationTokenExpired) {
+		return newError(codeInvalidRequest, "Invalid or expired verification token", err)
+	}
+	if isUniqueViolation(err) {
+		return newError(codeConflict, "Email is already in use", nil)
+	}
+	if err != nil {
+		return newError(codeInternal, "Couldn't verify email", err)
+	}
+
+	if previousEmail != "" {
+		cfg.sendMail(emailChangedEmail(previousEmail, newEmail))
+	}
+
+	respondWithJSON(w, http.StatusNoContent, nil)
+	return nil
+}
+
+// handlerResendEmailVerification godoc
+//
+//	@Router		/users/verify/resend [post]
+//	@Summary	resend the email verification link
+//	@Tags		users
+//	@Accept		json
+//	@Produce	json
+//	@Param		Authorization	header	string	true	"Bearer token"
+//	@Success	202		"Accepted"
+//	@Failure	401		{object}	Problem
+//	@Failure	409		{object}	Problem
+//	@Failure	429		{object}	Problem
+//	@Failure	500		{object}	Problem
+//	@Security	BearerAuth
+func (cfg *Config) handlerResendEmailVerification(w http.ResponseWriter, r *http.Request) error {
+	user, err := cfg.db.GetUserById(r.Context(), UserIDFromContext(r.Context()))
+	if err != nil {
+		return newError(codeInternal, "Couldn't get user", err)
+	}
+
+	if user.EmailVerifiedAt.Valid {
+		return newError(codeConflict, "Email address is already verified", nil)
+	}
+
+	if err := cfg.limitMailRequest(w, r, user.Email); err != nil {
+		return err
+	}
+
+	if err := cfg.sendEmailVerification(r.Context(), user.ID, user.Email); err != nil {
+		return newError(codeInternal, "Couldn't send verification email", err)
+	}
+
+	respondWithJSON(w, http.StatusAccepted, nil)
+	return nil
+}
@@ -0,0 +1,50 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// APIKeyHeader is the header API keys can be sent in instead of
+// Authorization.
+const APIKeyHeader = "X-API-Key"
+
+// apiKeyTag starts every API key, which tells them apart from JWTs when both
+// arrive as bearer tokens, and makes leaked keys easy to scan for.
+const apiKeyTag = "potom_"
+
+// MakeAPIKey returns a new API key and its prefix. The prefix is not secret
+// and identifies the key in listings; only a hash of the whole key should be
+// stored.
+func MakeAPIKey() (key, prefix string, err error) {
+	id := make([]byte, 6)
+	_, err = rand.Read(id)
+	if err != nil {
+		return "", "", err
+	}
+
+	secret := make([]byte, 32)
+	_, err = rand.Read(secret)
+	if err != nil {
+		return "", "", err
+	}
+
+	prefix = apiKeyTag + hex.EncodeToString(id)
+	return prefix + "_" + hex.EncodeToString(secret), prefix, nil
+}
+
+// IsAPIKey reports whether a bearer token is an API key rather than a JWT.
+func IsAPIKey(token string) bool {
+	return strings.HasPrefix(token, apiKeyTag)
+}
+
+// GetCredential returns the API key from the X-API-Key header if there is
+// one, and the bearer token otherwise.
+func GetCredential(headers http.Header) (string, error) {
+	if key := headers.Get(APIKeyHeader); key != "" {
+		return key, nil
+	}
+	return GetBearerToken(headers)
+}
@@ -2,81 +2,145 @@ package auth
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 	"errors"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
-	"github.com/gorilla/sessions"
-	"github.com/markbates/goth"
-	"github.com/markbates/goth/gothic"
-	"github.com/markbates/goth/providers/google"
-	"github.com/potom-dev/backend/internal/env"
 	"golang.org/x/crypto/bcrypt"
 )
 
-func NewAuth() {
-	googleClientID := env.GetEnv("GOOGLE_CLIENT_ID")
-	googleClientSecret := env.GetEnv("GOOGLE_CLIENT_SECRET")
-	googleRedirectURL := env.GetEnv("GOOGLE_REDIRECT_URL")
-	key := env.GetEnv("SESSION_KEY")
+// NoPassword is the password hash of accounts that can only sign in through
+// an OAuth provider. It is not a valid bcrypt hash, so no password matches it.
+const NoPassword = "unset"
 
-	store := sessions.NewCookieStore([]byte(key))
-	gothic.Store = store
-
-	goth.UseProviders(
-		google.New(googleClientID, googleClientSecret, googleRedirectURL),
-	)
-}
+// passwordCost is the bcrypt cost of new password hashes, set by NewAuth.
+var passwordCost = bcrypt.DefaultCost
 
 func HashPassword(password string) (string, error) {
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), passwordCost)
 	return string(hashedPassword), err
 }
 
+var errNoPassword = errors.New("account has no password")
+
 func CheckPassword(password, hashedPassword string) error {
+	if hashedPassword == NoPassword {
+		CheckDummyPassword(password)
+		return errNoPassword
+	}
 	return bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
 }
 
-func MakeJWT(userID uuid.UUID, tokenSecret string) (string, error) {
-	expiresAt := time.Now().Add(time.Minute * 15)
+var dummyPasswordHash = sync.OnceValue(func() []byte {
+	hash, err := bcrypt.GenerateFromPassword([]byte("dummy password"), passwordCost)
+	if err != nil {
+		panic(err)
+	}
+	return hash
+})
+
+// CheckDummyPassword spends as long as CheckPassword does on a real account,
+// so that failed logins don't reveal whether an account exists.
+func CheckDummyPassword(password string) {
+	bcrypt.CompareHashAndPassword(dummyPasswordHash(), []byte(password))
+}
+
+const (
+	tokenIssuer = "potom"
+	// TokenAudience is the audience of access tokens; services verifying
+	// them through the JWKS should check for it too.
+	TokenAudience = "potom-api"
+)
+
+// Claims are the claims of an access token.
+type Claims struct {
+	jwt.RegisteredClaims
+	Roles         []string `json:"roles,omitempty"`
+	EmailVerified bool     `json:"email_verified"`
+	SessionID     string   `json:"sid,omitempty"`
+
+	// UserID is the parsed subject, set by ValidateJWT.
+	UserID uuid.UUID `json:"-"`
+}
+
+// TokenSubject describes who an access token is issued to.
+type TokenSubject struct {
+	UserID        uuid.UUID
+	SessionID     uuid.UUID
+	Roles         []string
+	EmailVerified bool
+}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
-		Issuer:    "potom",
-		IssuedAt:  jwt.NewNumericDate(time.Now()),
-		ExpiresAt: jwt.NewNumericDate(expiresAt),
-		Subject:   userID.String(),
-	})
+// TokenLifetime bounds how long access tokens are valid.
+type TokenLifetime struct {
+	Default time.Duration
+	Max     time.Duration
+}
 
-	return token.SignedString([]byte(tokenSecret))
+// Clamp returns the lifetime to use when a client asks for requested, which
+// is zero when it didn't ask for anything.
+func (l TokenLifetime) Clamp(requested time.Duration) time.Duration {
+	if requested <= 0 {
+		return l.Default
+	}
+	return min(requested, l.Max)
 }
 
-func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &jwt.RegisteredClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return []byte(tokenSecret), nil
-	})
+func MakeJWT(subject TokenSubject, lifetime time.Duration, keys *KeyRing) (string, error) {
+	now := time.Now()
+
+	claims := Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    tokenIssuer,
+			Audience:  jwt.ClaimStrings{TokenAudience},
+			Subject:   subject.UserID.String(),
+			ID:        uuid.NewString(),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(lifetime)),
+		},
+		Roles:         subject.Roles,
+		EmailVerified: subject.EmailVerified,
+	}
+	if subject.SessionID != uuid.Nil {
+		claims.SessionID = subject.SessionID.String()
+	}
+
+	return keys.sign(claims)
+}
+
+func ValidateJWT(tokenString string, keys *KeyRing) (*Claims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, keys.verificationKey,
+		jwt.WithValidMethods([]string{"RS256", "EdDSA", "HS256"}),
+		jwt.WithIssuer(tokenIssuer),
+		jwt.WithAudience(TokenAudience),
+		jwt.WithExpirationRequired(),
+	)
 	if err != nil {
-		return uuid.Nil, err
+		return nil, err
 	}
 
 	if !token.Valid {
-		return uuid.Nil, errors.New("invalid token")
+		return nil, errors.New("invalid token")
 	}
 
-	claims, ok := token.Claims.(*jwt.RegisteredClaims)
+	claims, ok := token.Claims.(*Claims)
 	if !ok {
-		return uuid.Nil, errors.New("invalid token claims")
+		return nil, errors.New("invalid token claims")
 	}
 
-	userID, err := uuid.Parse(claims.Subject)
+	claims.UserID, err = uuid.Parse(claims.Subject)
 	if err != nil {
-		return uuid.Nil, err
+		return nil, err
 	}
 
-	return userID, nil
+	return claims, nil
 }
 
 func GetBearerToken(headers http.Header) (string, error) {
@@ -102,3 +166,10 @@ func MakeRefreshToken() (string, error) {
 
 	return hex.EncodeToString(data), nil
 }
+
+// HashToken returns the hex encoded SHA-256 digest of an opaque token, for
+// storing tokens that only ever need to be looked up, never read back.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,249 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"maps"
+	"math/big"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// activeKeyFile names the file in a key directory that holds the ID of the
+// key to sign with. Without it the last key ID in sort order is used.
+const activeKeyFile = "active"
+
+type signingKey struct {
+	id      string
+	method  jwt.SigningMethod
+	private crypto.Signer
+}
+
+// KeyRing holds the keys access tokens are signed and verified with. New
+// tokens are signed with the active key, and tokens signed with any other
+// key in the ring stay valid until they expire, so keys can be rotated
+// without logging anyone out:
+//
+//  1. add the new key to the directory and reload, which publishes it;
+//  2. once verifiers have refetched the JWKS, point the active file at it
+//     and reload again;
+//  3. remove the old key once the last tokens it signed have expired.
+//
+// A ring without a directory signs with the HS256 shared secret instead.
+type KeyRing struct {
+	dir    string
+	secret []byte
+
+	mu     sync.RWMutex
+	active *signingKey
+	keys   map[string]*signingKey
+}
+
+// NewHMACKeyRing returns a ring that signs and verifies with a shared HS256
+// secret only. Nothing is published in its JWKS.
+func NewHMACKeyRing(secret string) *KeyRing {
+	return &KeyRing{secret: []byte(secret)}
+}
+
+// LoadKeyRing reads PEM encoded RSA or Ed25519 private keys from dir, one per
+// file named <kid>.pem. If secret is not empty, HS256 tokens signed with it
+// are still accepted, to ease moving off the shared secret.
+func LoadKeyRing(dir, secret string) (*KeyRing, error) {
+	k := &KeyRing{dir: dir, secret: []byte(secret)}
+	if err := k.Reload(); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// Reload rereads the key directory. On error the ring keeps its old keys.
+func (k *KeyRing) Reload() error {
+	if k.dir == "" {
+		return nil
+	}
+
+	paths, err := filepath.Glob(filepath.Join(k.dir, "*.pem"))
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		return fmt.Errorf("no keys in %s", k.dir)
+	}
+	slices.Sort(paths)
+
+	keys := map[string]*signingKey{}
+	var ids []string
+	for _, path := range paths {
+		key, err := loadSigningKey(path)
+		if err != nil {
+			return fmt.Errorf("loading %s: %w", path, err)
+		}
+		keys[key.id] = key
+		ids = append(ids, key.id)
+	}
+
+	activeID := ids[len(ids)-1]
+	data, err := os.ReadFile(filepath.Join(k.dir, activeKeyFile))
+	if err == nil {
+		activeID = strings.TrimSpace(string(data))
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	active, ok := keys[activeID]
+	if !ok {
+		return fmt.Errorf("active key %q not found in %s", activeID, k.dir)
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.keys = keys
+	k.active = active
+	return nil
+}
+
+// ActiveKeyID returns the ID of the key new tokens are signed with, or ""
+// when signing with the shared secret.
+func (k *KeyRing) ActiveKeyID() string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	if k.active == nil {
+		return ""
+	}
+	return k.active.id
+}
+
+// sign signs the token with the active key.
+func (k *KeyRing) sign(claims jwt.Claims) (string, error) {
+	k.mu.RLock()
+	active := k.active
+	k.mu.RUnlock()
+
+	if active == nil {
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(k.secret)
+	}
+
+	token := jwt.NewWithClaims(active.method, claims)
+	token.Header["kid"] = active.id
+	return token.SignedString(active.private)
+}
+
+// verificationKey is the jwt.Keyfunc for tokens signed by this ring.
+func (k *KeyRing) verificationKey(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		if len(k.secret) == 0 || token.Method != jwt.SigningMethodHS256 {
+			return nil, errors.New("token has no key ID")
+		}
+		return k.secret, nil
+	}
+
+	k.mu.RLock()
+	key, ok := k.keys[kid]
+	k.mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown key ID %q", kid)
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("key %q can't verify %s", kid, token.Method.Alg())
+	}
+	return key.private.Public(), nil
+}
+
+// JWK is a public key in JSON Web Key format (RFC 7517).
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	// RSA keys
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// Ed25519 keys
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public halves of every key in the ring.
+func (k *KeyRing) JWKS() JWKSet {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	set := JWKSet{Keys: []JWK{}}
+	for _, id := range slices.Sorted(maps.Keys(k.keys)) {
+		key := k.keys[id]
+		jwk := JWK{Kid: key.id, Use: "sig", Alg: key.method.Alg()}
+
+		switch public := key.private.Public().(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		}
+
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	return set
+}
+
+func loadSigningKey(path string) (*signingKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data")
+	}
+
+	var parsed any
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key := &signingKey{id: strings.TrimSuffix(filepath.Base(path), ".pem")}
+	switch private := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.method = jwt.SigningMethodRS256
+		key.private = private
+	case ed25519.PrivateKey:
+		key.method = jwt.SigningMethodEdDSA
+		key.private = private
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", parsed)
+	}
+
+	return key, nil
+}
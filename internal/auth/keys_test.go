@@ -0,0 +1,334 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const testSecret = "test-secret"
+
+// writeKey stores key in dir as <kid>.pem and returns its PEM encoding.
+func writeKey(t *testing.T, dir, kid string, key crypto.Signer) []byte {
+	t.Helper()
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if err := os.WriteFile(filepath.Join(dir, kid+".pem"), data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func newRSAKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func newEd25519Key(t *testing.T) ed25519.PrivateKey {
+	t.Helper()
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// validClaims are claims ValidateJWT accepts, for hand-made tokens.
+func validClaims() Claims {
+	now := time.Now()
+	return Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    tokenIssuer,
+			Audience:  jwt.ClaimStrings{TokenAudience},
+			Subject:   uuid.NewString(),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+		},
+	}
+}
+
+// signToken signs claims with method and key, setting the kid header unless
+// it is empty.
+func signToken(t *testing.T, method jwt.SigningMethod, kid string, key any, claims Claims) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	s, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestLoadKeyRingActiveKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		kids    []string
+		active  string
+		want    string
+		wantErr bool
+	}{
+		{name: "last in sort order", kids: []string{"2024-01", "2025-01"}, want: "2025-01"},
+		{name: "active file", kids: []string{"2024-01", "2025-01"}, active: "2024-01", want: "2024-01"},
+		{name: "active file with newline", kids: []string{"2024-01", "2025-01"}, active: "2024-01\n", want: "2024-01"},
+		{name: "unknown active key", kids: []string{"2024-01"}, active: "2023-01", wantErr: true},
+		{name: "no keys", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, kid := range tt.kids {
+				writeKey(t, dir, kid, newEd25519Key(t))
+			}
+			if tt.active != "" {
+				if err := os.WriteFile(filepath.Join(dir, activeKeyFile), []byte(tt.active), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			keys, err := LoadKeyRing(dir, "")
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("LoadKeyRing() = active %q, want an error", keys.ActiveKeyID())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadKeyRing() error = %v", err)
+			}
+			if got := keys.ActiveKeyID(); got != tt.want {
+				t.Errorf("ActiveKeyID() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeyRingReloadKeepsKeysOnError(t *testing.T) {
+	dir := t.TempDir()
+	writeKey(t, dir, "a", newEd25519Key(t))
+
+	keys, err := LoadKeyRing(dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "b.pem"), []byte("not a key"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := keys.Reload(); err == nil {
+		t.Fatal("Reload() with a broken key succeeded")
+	}
+	if got := keys.ActiveKeyID(); got != "a" {
+		t.Errorf("ActiveKeyID() after failed reload = %q, want a", got)
+	}
+}
+
+func TestMakeJWTSignsWithActiveKey(t *testing.T) {
+	dir := t.TempDir()
+	writeKey(t, dir, "ed", newEd25519Key(t))
+	writeKey(t, dir, "rsa", newRSAKey(t))
+
+	tests := []struct {
+		active  string
+		wantAlg string
+	}{
+		{"ed", "EdDSA"},
+		{"rsa", "RS256"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.active, func(t *testing.T) {
+			if err := os.WriteFile(filepath.Join(dir, activeKeyFile), []byte(tt.active), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			keys, err := LoadKeyRing(dir, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			userID := uuid.New()
+			token, err := MakeJWT(TokenSubject{UserID: userID}, time.Minute, keys)
+			if err != nil {
+				t.Fatalf("MakeJWT() error = %v", err)
+			}
+
+			parsed, _, err := jwt.NewParser().ParseUnverified(token, &Claims{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if kid := parsed.Header["kid"]; kid != tt.active {
+				t.Errorf("kid = %v, want %s", kid, tt.active)
+			}
+			if alg := parsed.Method.Alg(); alg != tt.wantAlg {
+				t.Errorf("alg = %s, want %s", alg, tt.wantAlg)
+			}
+
+			claims, err := ValidateJWT(token, keys)
+			if err != nil {
+				t.Fatalf("ValidateJWT() error = %v", err)
+			}
+			if claims.UserID != userID {
+				t.Errorf("UserID = %s, want %s", claims.UserID, userID)
+			}
+		})
+	}
+}
+
+func TestValidateJWTKeySelection(t *testing.T) {
+	dir := t.TempDir()
+	oldKey := newEd25519Key(t)
+	writeKey(t, dir, "old", oldKey)
+	writeKey(t, dir, "new", newEd25519Key(t))
+
+	withSecret, err := LoadKeyRing(dir, testSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	withoutSecret, err := LoadKeyRing(dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hmacOnly := NewHMACKeyRing(testSecret)
+
+	hs256 := signToken(t, jwt.SigningMethodHS256, "", []byte(testSecret), validClaims())
+	rotated := signToken(t, jwt.SigningMethodEdDSA, "old", oldKey, validClaims())
+	unknownKid := signToken(t, jwt.SigningMethodEdDSA, "gone", newEd25519Key(t), validClaims())
+	wrongSecret := signToken(t, jwt.SigningMethodHS256, "", []byte("other-secret"), validClaims())
+
+	tests := []struct {
+		name  string
+		token string
+		keys  *KeyRing
+		valid bool
+	}{
+		{"HS256 without kid on shared secret ring", hs256, hmacOnly, true},
+		{"HS256 without kid during migration", hs256, withSecret, true},
+		{"HS256 without kid once secret is gone", hs256, withoutSecret, false},
+		{"HS256 with wrong secret", wrongSecret, hmacOnly, false},
+		{"key rotated out of active", rotated, withoutSecret, true},
+		{"unknown kid", unknownKid, withSecret, false},
+		{"kid on shared secret ring", rotated, hmacOnly, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ValidateJWT(tt.token, tt.keys)
+			if tt.valid && err != nil {
+				t.Errorf("ValidateJWT() error = %v", err)
+			}
+			if !tt.valid && err == nil {
+				t.Error("ValidateJWT() accepted the token")
+			}
+		})
+	}
+}
+
+func TestValidateJWTRejectsAlgConfusion(t *testing.T) {
+	dir := t.TempDir()
+	rsaKey := newRSAKey(t)
+	edKey := newEd25519Key(t)
+	writeKey(t, dir, "rsa", rsaKey)
+	writeKey(t, dir, "ed", edKey)
+
+	keys, err := LoadKeyRing(dir, testSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	publicDER, err := x509.MarshalPKIXPublicKey(rsaKey.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{
+			// The classic confusion: the published public key used as an
+			// HMAC secret.
+			name:  "HS256 signed with RSA public key",
+			token: signToken(t, jwt.SigningMethodHS256, "rsa", publicPEM, validClaims()),
+		},
+		{
+			name:  "HS256 with kid signed with shared secret",
+			token: signToken(t, jwt.SigningMethodHS256, "rsa", []byte(testSecret), validClaims()),
+		},
+		{
+			name:  "EdDSA claiming RSA kid",
+			token: signToken(t, jwt.SigningMethodEdDSA, "rsa", edKey, validClaims()),
+		},
+		{
+			name:  "RS256 without kid",
+			token: signToken(t, jwt.SigningMethodRS256, "", rsaKey, validClaims()),
+		},
+		{
+			name:  "HS384 with shared secret",
+			token: signToken(t, jwt.SigningMethodHS384, "", []byte(testSecret), validClaims()),
+		},
+		{
+			name:  "alg none",
+			token: signToken(t, jwt.SigningMethodNone, "", jwt.UnsafeAllowNoneSignatureType, validClaims()),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ValidateJWT(tt.token, keys); err == nil {
+				t.Error("ValidateJWT() accepted the token")
+			}
+		})
+	}
+}
+
+func TestValidateJWTClaims(t *testing.T) {
+	keys := NewHMACKeyRing(testSecret)
+
+	tests := []struct {
+		name   string
+		modify func(*Claims)
+	}{
+		{"expired", func(c *Claims) { c.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute)) }},
+		{"no expiry", func(c *Claims) { c.ExpiresAt = nil }},
+		{"wrong issuer", func(c *Claims) { c.Issuer = "someone-else" }},
+		{"wrong audience", func(c *Claims) { c.Audience = jwt.ClaimStrings{"other-api"} }},
+		{"subject not a UUID", func(c *Claims) { c.Subject = "admin" }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := validClaims()
+			tt.modify(&claims)
+			token := signToken(t, jwt.SigningMethodHS256, "", []byte(testSecret), claims)
+
+			if _, err := ValidateJWT(token, keys); err == nil {
+				t.Error("ValidateJWT() accepted the token")
+			}
+		})
+	}
+}
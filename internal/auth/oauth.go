@@ -0,0 +1,135 @@
+package auth
+
+import (
+	"cmp"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gorilla/sessions"
+	"github.com/markbates/goth"
+	"github.com/markbates/goth/gothic"
+	"github.com/markbates/goth/providers/apple"
+	"github.com/markbates/goth/providers/github"
+	"github.com/markbates/goth/providers/google"
+	"github.com/markbates/goth/providers/openidConnect"
+	"github.com/potom-dev/backend/internal/config"
+)
+
+// appleSecretLifetime is how long the client secret signed at startup stays
+// valid. Apple accepts at most six months, so the server has to be restarted
+// at least that often while Sign in with Apple is enabled.
+const appleSecretLifetime = time.Hour * 24 * 180
+
+var errProviderNotConfigured = errors.New("provider is not configured")
+
+type oauthProvider struct {
+	name string
+	// new creates the provider from cfg, with callbackURL as the callback
+	// unless cfg sets one.
+	new func(cfg config.OAuthConfig, callbackURL string) (goth.Provider, error)
+}
+
+var oauthProviders = []oauthProvider{
+	{name: "google", new: newGoogleProvider},
+	{name: "github", new: newGithubProvider},
+	{name: "apple", new: newAppleProvider},
+	{name: "openid-connect", new: newOpenIDConnectProvider},
+}
+
+// NewAuth sets the cost of new password hashes and registers every OAuth
+// provider whose credentials are set. A provider's callback is its
+// configured redirect URL, or the API's own callback route under the API
+// URL when unset.
+func NewAuth(cfg config.Config) {
+	passwordCost = cfg.Auth.BcryptCost
+
+	store := sessions.NewCookieStore([]byte(cfg.OAuth.SessionKey))
+	store.Options.HttpOnly = true
+	gothic.Store = store
+
+	enabled := []string{}
+	for _, provider := range oauthProviders {
+		callbackURL := strings.TrimRight(cfg.APIURL, "/") + "/api/auth/" + provider.name + "/callback"
+
+		p, err := provider.new(cfg.OAuth, callbackURL)
+		if errors.Is(err, errProviderNotConfigured) {
+			continue
+		}
+		if err != nil {
+			log.Printf("Disabling OAuth provider %s: %v", provider.name, err)
+			continue
+		}
+
+		goth.UseProviders(p)
+		enabled = append(enabled, provider.name)
+	}
+
+	if _, err := goth.GetProvider("apple"); err == nil {
+		// Apple posts the callback from its own origin, which browsers only
+		// send cookies along with when they are SameSite=None.
+		store.Options.SameSite = http.SameSiteNoneMode
+		store.Options.Secure = true
+	}
+
+	log.Printf("OAuth providers enabled: %s", strings.Join(enabled, ", "))
+}
+
+func newGoogleProvider(cfg config.OAuthConfig, callbackURL string) (goth.Provider, error) {
+	c := cfg.Google
+	if !c.Configured() {
+		return nil, errProviderNotConfigured
+	}
+
+	return google.New(c.ClientID, string(c.ClientSecret), cmp.Or(c.RedirectURL, callbackURL)), nil
+}
+
+func newGithubProvider(cfg config.OAuthConfig, callbackURL string) (goth.Provider, error) {
+	c := cfg.GitHub
+	if !c.Configured() {
+		return nil, errProviderNotConfigured
+	}
+
+	return github.New(c.ClientID, string(c.ClientSecret), cmp.Or(c.RedirectURL, callbackURL), "user:email"), nil
+}
+
+func newAppleProvider(cfg config.OAuthConfig, callbackURL string) (goth.Provider, error) {
+	c := cfg.Apple
+	if !c.Configured() {
+		return nil, errProviderNotConfigured
+	}
+
+	key, err := os.ReadFile(c.PrivateKeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	secret, err := apple.MakeSecret(apple.SecretParams{
+		PKCS8PrivateKey: string(key),
+		TeamId:          c.TeamID,
+		KeyId:           c.KeyID,
+		ClientId:        c.ClientID,
+		Iat:             int(now.Unix()),
+		Exp:             int(now.Add(appleSecretLifetime).Unix()),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return apple.New(c.ClientID, *secret, cmp.Or(c.RedirectURL, callbackURL), nil, apple.ScopeName, apple.ScopeEmail), nil
+}
+
+// newOpenIDConnectProvider configures a generic OpenID Connect provider from
+// its discovery document, e.g. a local mock issuer during development.
+func newOpenIDConnectProvider(cfg config.OAuthConfig, callbackURL string) (goth.Provider, error) {
+	c := cfg.OIDC
+	if !c.Configured() {
+		return nil, errProviderNotConfigured
+	}
+
+	return openidConnect.New(c.ClientID, string(c.ClientSecret), cmp.Or(c.RedirectURL, callbackURL), c.DiscoveryURL)
+}
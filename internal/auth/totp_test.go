@@ -0,0 +1,174 @@
+package auth
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA-1 secret of the RFC 6238 test vectors.
+var rfc6238Secret = totpEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestValidateTOTPVectors(t *testing.T) {
+	// The RFC lists 8 digit codes; 6 digit codes are their last six digits.
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, tt := range tests {
+		step, err := ValidateTOTP(rfc6238Secret, tt.code, time.Unix(tt.unix, 0))
+		if err != nil {
+			t.Errorf("ValidateTOTP(%s) at %d: %v", tt.code, tt.unix, err)
+			continue
+		}
+		if want := tt.unix / totpPeriod; step != want {
+			t.Errorf("ValidateTOTP(%s) at %d = step %d, want %d", tt.code, tt.unix, step, want)
+		}
+	}
+}
+
+func TestValidateTOTPSkew(t *testing.T) {
+	const step = 1111111109 / totpPeriod
+	key, _ := totpEncoding.DecodeString(rfc6238Secret)
+	code := totpCode(key, step)
+
+	tests := []struct {
+		name   string
+		offset int64
+		valid  bool
+	}{
+		{"two steps early", -2, false},
+		{"one step early", -1, true},
+		{"current step", 0, true},
+		{"one step late", 1, true},
+		{"two steps late", 2, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Unix((step+tt.offset)*totpPeriod, 0)
+			got, err := ValidateTOTP(rfc6238Secret, code, now)
+			if !tt.valid {
+				if !errors.Is(err, ErrInvalidTOTP) {
+					t.Fatalf("ValidateTOTP() error = %v, want ErrInvalidTOTP", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ValidateTOTP() error = %v", err)
+			}
+			if got != step {
+				t.Errorf("ValidateTOTP() = step %d, want %d", got, step)
+			}
+		})
+	}
+}
+
+func TestValidateTOTPInput(t *testing.T) {
+	now := time.Unix(59, 0)
+
+	tests := []struct {
+		name   string
+		secret string
+		code   string
+		valid  bool
+	}{
+		{"surrounding space", rfc6238Secret, " 287082\n", true},
+		{"lower case secret", "gezdgnbvgy3tqojqgezdgnbvgy3tqojq", "287082", true},
+		{"wrong code", rfc6238Secret, "287083", false},
+		{"too short", rfc6238Secret, "28708", false},
+		{"eight digits", rfc6238Secret, "94287082", false},
+		{"empty", rfc6238Secret, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ValidateTOTP(tt.secret, tt.code, now)
+			if tt.valid && err != nil {
+				t.Errorf("ValidateTOTP() error = %v", err)
+			}
+			if !tt.valid && !errors.Is(err, ErrInvalidTOTP) {
+				t.Errorf("ValidateTOTP() error = %v, want ErrInvalidTOTP", err)
+			}
+		})
+	}
+}
+
+func TestValidateTOTPBadSecret(t *testing.T) {
+	_, err := ValidateTOTP("not base32!", "123456", time.Now())
+	if err == nil || errors.Is(err, ErrInvalidTOTP) {
+		t.Errorf("ValidateTOTP() error = %v, want a decoding error", err)
+	}
+}
+
+func TestMakeRecoveryCode(t *testing.T) {
+	format := regexp.MustCompile(`^[a-z2-7]{4}-[a-z2-7]{4}-[a-z2-7]{4}-[a-z2-7]{4}$`)
+	seen := map[string]bool{}
+
+	for range 100 {
+		code, err := MakeRecoveryCode()
+		if err != nil {
+			t.Fatalf("MakeRecoveryCode() error = %v", err)
+		}
+		if !format.MatchString(code) {
+			t.Fatalf("MakeRecoveryCode() = %q, want xxxx-xxxx-xxxx-xxxx", code)
+		}
+		if seen[code] {
+			t.Fatalf("MakeRecoveryCode() returned %q twice", code)
+		}
+		seen[code] = true
+	}
+}
+
+func TestRecoveryCodeHash(t *testing.T) {
+	const code = "abcd-efgh-ijkl-mnop"
+	want := HashToken(NormalizeRecoveryCode(code))
+
+	tests := []struct {
+		name  string
+		typed string
+		match bool
+	}{
+		{"as issued", "abcd-efgh-ijkl-mnop", true},
+		{"upper case", "ABCD-EFGH-IJKL-MNOP", true},
+		{"without dashes", "abcdefghijklmnop", true},
+		{"with spaces", "abcd efgh ijkl mnop", true},
+		{"mixed", " Abcd-EFGH ijkl-mnoP ", true},
+		{"other code", "abcd-efgh-ijkl-mnoq", false},
+		{"truncated", "abcd-efgh-ijkl", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := HashToken(NormalizeRecoveryCode(tt.typed))
+			if (got == want) != tt.match {
+				t.Errorf("hash of %q matches = %v, want %v", tt.typed, got == want, tt.match)
+			}
+		})
+	}
+}
+
+func TestHashToken(t *testing.T) {
+	tests := []struct {
+		token string
+		want  string
+	}{
+		{"", "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"},
+		{"abc", "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+	}
+
+	for _, tt := range tests {
+		if got := HashToken(tt.token); got != tt.want {
+			t.Errorf("HashToken(%q) = %s, want %s", tt.token, got, tt.want)
+		}
+	}
+}
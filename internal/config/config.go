@@ -0,0 +1,307 @@
+// Package config holds the settings of the server. They are read once at
+// startup from defaults, an optional YAML file, the environment and command
+// line flags, each overriding the one before, and checked all together so a
+// misconfigured deployment reports every problem at once.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the configuration of the server. A setting is read from the
+// environment variable in its env tag, prefixed with the env tags of the
+// structs it is nested in, and from the flag of the same name in lower case
+// with dashes, e.g. -access-token-ttl.
+type Config struct {
+	// Platform is "dev" on development machines, which enables endpoints
+	// that must never be reachable in production.
+	Platform string `yaml:"platform" env:"PLATFORM" usage:"deployment platform, dev enables development-only endpoints"`
+	// AppURL is the base URL of the frontend, for links in outgoing email.
+	AppURL string `yaml:"app_url" env:"APP_URL" usage:"base URL of the frontend"`
+	// APIURL is the base URL of this API, for default OAuth callback URLs.
+	APIURL string `yaml:"api_url" env:"API_URL" usage:"base URL of this API"`
+
+	HTTP     HTTPConfig     `yaml:"http"`
+	Database DatabaseConfig `yaml:"database"`
+	Auth     AuthConfig     `yaml:"auth"`
+	OAuth    OAuthConfig    `yaml:"oauth"`
+	Mail     MailConfig     `yaml:"mail"`
+}
+
+type HTTPConfig struct {
+	Port              int           `yaml:"port" env:"PORT" usage:"port to listen on"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout" env:"HTTP_READ_HEADER_TIMEOUT" usage:"longest time to read request headers"`
+	ReadTimeout       time.Duration `yaml:"read_timeout" env:"HTTP_READ_TIMEOUT" usage:"longest time to read a whole request"`
+	WriteTimeout      time.Duration `yaml:"write_timeout" env:"HTTP_WRITE_TIMEOUT" usage:"longest time to write a response"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout" env:"HTTP_IDLE_TIMEOUT" usage:"how long an idle keep-alive connection stays open"`
+	MaxHeaderBytes    int           `yaml:"max_header_bytes" env:"HTTP_MAX_HEADER_BYTES" usage:"largest size of request headers"`
+	// ShutdownDelay is how long the server keeps taking requests after
+	// SIGTERM with readiness failing, for load balancers to notice.
+	ShutdownDelay time.Duration `yaml:"shutdown_delay" env:"HTTP_SHUTDOWN_DELAY" usage:"how long to fail readiness before draining on shutdown"`
+	// ShutdownTimeout is how long in-flight requests get to finish after
+	// SIGTERM before their connections are closed.
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env:"HTTP_SHUTDOWN_TIMEOUT" usage:"how long to drain requests on shutdown"`
+	// TrustedProxies lists the addresses or CIDR ranges of the reverse
+	// proxies in front of the server. X-Forwarded-For is only believed when
+	// a request comes from one of them, and ignored when the list is empty.
+	TrustedProxies string `yaml:"trusted_proxies" env:"TRUSTED_PROXIES" usage:"comma-separated proxy addresses or CIDR ranges whose X-Forwarded-For is trusted"`
+}
+
+// TrustedProxyPrefixes parses TrustedProxies. A plain address is a range of
+// one.
+func (c HTTPConfig) TrustedProxyPrefixes() ([]netip.Prefix, error) {
+	prefixes := []netip.Prefix{}
+	for _, s := range strings.Split(c.TrustedProxies, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		if addr, err := netip.ParseAddr(s); err == nil {
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			return nil, fmt.Errorf("TRUSTED_PROXIES: %q is neither an address nor a CIDR range", s)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+type DatabaseConfig struct {
+	URL Secret `yaml:"url" env:"DB_URL" usage:"Postgres connection string"`
+	// ConnectTimeout is how long startup keeps retrying to reach the
+	// database, which may come up after the server.
+	ConnectTimeout time.Duration `yaml:"connect_timeout" env:"DB_CONNECT_TIMEOUT" usage:"how long to retry connecting at startup"`
+	// MaxOpenConns is zero for no limit.
+	MaxOpenConns    int           `yaml:"max_open_conns" env:"DB_MAX_OPEN_CONNS" usage:"most open connections, 0 for no limit"`
+	MaxIdleConns    int           `yaml:"max_idle_conns" env:"DB_MAX_IDLE_CONNS" usage:"most idle connections kept open"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime" env:"DB_CONN_MAX_LIFETIME" usage:"how long a connection is reused, 0 for ever"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time" env:"DB_CONN_MAX_IDLE_TIME" usage:"how long a connection may sit idle, 0 for ever"`
+}
+
+type AuthConfig struct {
+	// JWTSecret signs access tokens with HS256 unless JWTKeysDir is set. It
+	// is also the fallback key while the key directory is being set up.
+	JWTSecret Secret `yaml:"jwt_secret" env:"JWT_SECRET" usage:"HS256 secret to sign access tokens with"`
+	// JWTKeysDir holds <kid>.pem private keys, and optionally an "active"
+	// file naming the signing key.
+	JWTKeysDir string `yaml:"jwt_keys_dir" env:"JWT_KEYS_DIR" usage:"directory of private keys to sign access tokens with"`
+	// AccessTokenTTL is the lifetime of access tokens, unless a client asks
+	// for any lifetime up to AccessTokenMaxTTL.
+	AccessTokenTTL    time.Duration `yaml:"access_token_ttl" env:"ACCESS_TOKEN_TTL" usage:"default access token lifetime"`
+	AccessTokenMaxTTL time.Duration `yaml:"access_token_max_ttl" env:"ACCESS_TOKEN_MAX_TTL" usage:"longest access token lifetime a client may ask for"`
+	BcryptCost        int           `yaml:"bcrypt_cost" env:"BCRYPT_COST" usage:"bcrypt cost of new password hashes"`
+	// RequireVerifiedEmail keeps unverified users from creating or joining
+	// groups. They can still log in.
+	RequireVerifiedEmail bool `yaml:"require_verified_email" env:"REQUIRE_VERIFIED_EMAIL" usage:"require a verified email to create or join groups"`
+	// BootstrapAdminEmail becomes an admin on startup while there is none.
+	BootstrapAdminEmail string `yaml:"bootstrap_admin_email" env:"BOOTSTRAP_ADMIN_EMAIL" usage:"email of the account to make the first admin"`
+	// LockoutStore and DenylistStore are "memory", or "postgres" to share
+	// failed logins and revoked tokens between instances.
+	LockoutStore         string        `yaml:"lockout_store" env:"LOCKOUT_STORE" usage:"where failed logins are counted: memory or postgres"`
+	DenylistStore        string        `yaml:"denylist_store" env:"DENYLIST_STORE" usage:"where revoked access tokens are kept: memory or postgres"`
+	DenylistSyncInterval time.Duration `yaml:"denylist_sync_interval" env:"DENYLIST_SYNC_INTERVAL" usage:"how often revoked tokens are synced from the store"`
+}
+
+// OAuthConfig configures the OAuth providers. A provider is enabled by
+// setting its credentials, and its callback defaults to
+// $API_URL/api/auth/<provider>/callback.
+type OAuthConfig struct {
+	// SessionKey signs the cookie that carries the OAuth state.
+	SessionKey Secret            `yaml:"session_key" env:"SESSION_KEY" usage:"key to sign the OAuth session cookie with"`
+	Google     OAuthClientConfig `yaml:"google" env:"GOOGLE_"`
+	GitHub     OAuthClientConfig `yaml:"github" env:"GITHUB_"`
+	Apple      AppleConfig       `yaml:"apple" env:"APPLE_"`
+	OIDC       OIDCConfig        `yaml:"oidc" env:"OIDC_"`
+}
+
+type OAuthClientConfig struct {
+	ClientID     string `yaml:"client_id" env:"CLIENT_ID" usage:"OAuth client ID"`
+	ClientSecret Secret `yaml:"client_secret" env:"CLIENT_SECRET" usage:"OAuth client secret"`
+	RedirectURL  string `yaml:"redirect_url" env:"REDIRECT_URL" usage:"OAuth callback URL"`
+}
+
+func (c OAuthClientConfig) Configured() bool {
+	return c.ClientID != "" && c.ClientSecret != ""
+}
+
+type AppleConfig struct {
+	ClientID       string `yaml:"client_id" env:"CLIENT_ID" usage:"Sign in with Apple services ID"`
+	TeamID         string `yaml:"team_id" env:"TEAM_ID" usage:"Apple developer team ID"`
+	KeyID          string `yaml:"key_id" env:"KEY_ID" usage:"ID of the Sign in with Apple private key"`
+	PrivateKeyFile string `yaml:"private_key_file" env:"PRIVATE_KEY_FILE" usage:"PKCS #8 file of the Sign in with Apple private key"`
+	RedirectURL    string `yaml:"redirect_url" env:"REDIRECT_URL" usage:"OAuth callback URL"`
+}
+
+func (c AppleConfig) Configured() bool {
+	return c.ClientID != "" && c.TeamID != "" && c.KeyID != "" && c.PrivateKeyFile != ""
+}
+
+// OIDCConfig configures a generic OpenID Connect provider from its discovery
+// document, e.g. a local mock issuer during development.
+type OIDCConfig struct {
+	ClientID     string `yaml:"client_id" env:"CLIENT_ID" usage:"OpenID Connect client ID"`
+	ClientSecret Secret `yaml:"client_secret" env:"CLIENT_SECRET" usage:"OpenID Connect client secret"`
+	DiscoveryURL string `yaml:"discovery_url" env:"DISCOVERY_URL" usage:"OpenID Connect discovery document URL"`
+	RedirectURL  string `yaml:"redirect_url" env:"REDIRECT_URL" usage:"OAuth callback URL"`
+}
+
+func (c OIDCConfig) Configured() bool {
+	return c.ClientID != "" && c.ClientSecret != "" && c.DiscoveryURL != ""
+}
+
+type MailConfig struct {
+	// Mailer is "smtp", "file" to append mail to File, or "stdout".
+	Mailer string     `yaml:"mailer" env:"MAILER" usage:"mail transport: smtp, file or stdout"`
+	From   string     `yaml:"from" env:"MAIL_FROM" usage:"sender of outgoing email"`
+	File   string     `yaml:"file" env:"MAIL_FILE" usage:"file the file mailer appends to"`
+	SMTP   SMTPConfig `yaml:"smtp" env:"SMTP_"`
+}
+
+type SMTPConfig struct {
+	Host     string `yaml:"host" env:"HOST" usage:"SMTP server host"`
+	Port     int    `yaml:"port" env:"PORT" usage:"SMTP server port"`
+	Username string `yaml:"username" env:"USERNAME" usage:"SMTP username"`
+	Password Secret `yaml:"password" env:"PASSWORD" usage:"SMTP password"`
+}
+
+// Default returns the configuration used for anything that isn't set,
+// suitable for local development.
+func Default() Config {
+	return Config{
+		AppURL: "http://localhost:3000",
+		APIURL: "http://localhost:8080",
+		HTTP: HTTPConfig{
+			Port:              8080,
+			ReadHeaderTimeout: 5 * time.Second,
+			ReadTimeout:       15 * time.Second,
+			WriteTimeout:      30 * time.Second,
+			IdleTimeout:       2 * time.Minute,
+			MaxHeaderBytes:    64 << 10,
+			ShutdownTimeout:   20 * time.Second,
+		},
+		Database: DatabaseConfig{
+			ConnectTimeout:  30 * time.Second,
+			MaxOpenConns:    25,
+			MaxIdleConns:    25,
+			ConnMaxLifetime: 30 * time.Minute,
+			ConnMaxIdleTime: 5 * time.Minute,
+		},
+		Auth: AuthConfig{
+			AccessTokenTTL:       15 * time.Minute,
+			AccessTokenMaxTTL:    time.Hour,
+			BcryptCost:           bcrypt.DefaultCost,
+			RequireVerifiedEmail: true,
+			LockoutStore:         "memory",
+			DenylistStore:        "memory",
+			DenylistSyncInterval: 5 * time.Second,
+		},
+		Mail: MailConfig{
+			Mailer: "stdout",
+			From:   "potom <no-reply@localhost>",
+			File:   "mail.log",
+			SMTP: SMTPConfig{
+				Port: 587,
+			},
+		},
+	}
+}
+
+// Validate checks the whole configuration and returns every problem with it
+// joined together.
+func (c Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(isHTTPURL(c.AppURL), "APP_URL must be an http or https URL")
+	check(isHTTPURL(c.APIURL), "API_URL must be an http or https URL")
+	check(c.HTTP.Port > 0 && c.HTTP.Port <= 65535, "PORT must be between 1 and 65535")
+	check(c.HTTP.ReadHeaderTimeout > 0, "HTTP_READ_HEADER_TIMEOUT must be positive")
+	check(c.HTTP.ReadTimeout > 0, "HTTP_READ_TIMEOUT must be positive")
+	check(c.HTTP.WriteTimeout > 0, "HTTP_WRITE_TIMEOUT must be positive")
+	check(c.HTTP.IdleTimeout > 0, "HTTP_IDLE_TIMEOUT must be positive")
+	check(c.HTTP.MaxHeaderBytes > 0, "HTTP_MAX_HEADER_BYTES must be positive")
+	check(c.HTTP.ShutdownDelay >= 0, "HTTP_SHUTDOWN_DELAY must not be negative")
+	check(c.HTTP.ShutdownTimeout > 0, "HTTP_SHUTDOWN_TIMEOUT must be positive")
+	if _, err := c.HTTP.TrustedProxyPrefixes(); err != nil {
+		errs = append(errs, err)
+	}
+
+	check(c.Database.URL != "", "DB_URL is required")
+	check(c.Database.ConnectTimeout > 0, "DB_CONNECT_TIMEOUT must be positive")
+	check(c.Database.MaxOpenConns >= 0, "DB_MAX_OPEN_CONNS must not be negative")
+	check(c.Database.MaxIdleConns >= 0, "DB_MAX_IDLE_CONNS must not be negative")
+	check(c.Database.ConnMaxLifetime >= 0, "DB_CONN_MAX_LIFETIME must not be negative")
+	check(c.Database.ConnMaxIdleTime >= 0, "DB_CONN_MAX_IDLE_TIME must not be negative")
+
+	check(c.Auth.JWTSecret != "" || c.Auth.JWTKeysDir != "", "JWT_SECRET is required unless JWT_KEYS_DIR is set")
+	check(c.Auth.AccessTokenTTL > 0, "ACCESS_TOKEN_TTL must be positive")
+	check(c.Auth.AccessTokenTTL <= c.Auth.AccessTokenMaxTTL, "ACCESS_TOKEN_TTL is longer than ACCESS_TOKEN_MAX_TTL")
+	check(c.Auth.BcryptCost >= bcrypt.MinCost && c.Auth.BcryptCost <= bcrypt.MaxCost,
+		"BCRYPT_COST must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+	check(slices.Contains([]string{"memory", "postgres"}, c.Auth.LockoutStore), "LOCKOUT_STORE must be memory or postgres")
+	check(slices.Contains([]string{"memory", "postgres"}, c.Auth.DenylistStore), "DENYLIST_STORE must be memory or postgres")
+	check(c.Auth.DenylistSyncInterval > 0, "DENYLIST_SYNC_INTERVAL must be positive")
+
+	anyProvider := c.OAuth.Google.Configured() || c.OAuth.GitHub.Configured() ||
+		c.OAuth.Apple.Configured() || c.OAuth.OIDC.Configured()
+	check(!anyProvider || c.OAuth.SessionKey != "", "SESSION_KEY is required when an OAuth provider is configured")
+
+	check(slices.Contains([]string{"smtp", "file", "stdout"}, c.Mail.Mailer), "MAILER must be smtp, file or stdout")
+	check(c.Mail.Mailer != "smtp" || c.Mail.SMTP.Host != "", "SMTP_HOST is required when MAILER is smtp")
+	check(c.Mail.Mailer != "file" || c.Mail.File != "", "MAIL_FILE is required when MAILER is file")
+	check(c.Mail.SMTP.Port > 0 && c.Mail.SMTP.Port <= 65535, "SMTP_PORT must be between 1 and 65535")
+
+	return errors.Join(errs...)
+}
+
+func isHTTPURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "https" || u.Scheme == "http") && u.Host != ""
+}
+
+// String formats the configuration as YAML, with secrets redacted, for
+// logging at startup.
+func (c Config) String() string {
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return err.Error()
+	}
+	return string(data)
+}
+
+// Secret is a setting that must not show up in logs. It prints as
+// [redacted] when set; use string(s) for the actual value.
+type Secret string
+
+const redacted = "[redacted]"
+
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return redacted
+}
+
+func (s Secret) MarshalYAML() (any, error) {
+	return s.String(), nil
+}
+
+func (s Secret) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + s.String() + `"`), nil
+}
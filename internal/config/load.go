@@ -0,0 +1,164 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// Load reads the configuration for a server started with args, the command
+// line without the program name. The file named by -config or CONFIG_FILE is
+// read first, then the environment, including a .env file in the working
+// directory, and then the other flags. It returns flag.ErrHelp when args ask
+// for the usage, and otherwise every problem it found joined together.
+//
+// Empty environment variables are skipped, since .env.template leaves many
+// of them empty and a copy of it mustn't clear the file's settings. A flag
+// set to an empty value, e.g. -trusted-proxies=, does clear one.
+func Load(args []string) (Config, error) {
+	godotenv.Load()
+
+	cfg := Default()
+	settings := settingsOf(reflect.ValueOf(&cfg).Elem(), "")
+
+	flags := flag.NewFlagSet("backend", flag.ContinueOnError)
+	file := flags.String("config", os.Getenv("CONFIG_FILE"), "YAML `file` to read the configuration from")
+	values := make([]*flagValue, len(settings))
+	for i, s := range settings {
+		values[i] = &flagValue{setting: s}
+		flags.Var(values[i], s.flagName(), s.usage+" (env "+s.env+")")
+	}
+	if err := flags.Parse(args); err != nil {
+		return cfg, err
+	}
+
+	if *file != "" {
+		if err := loadFile(*file, &cfg); err != nil {
+			return cfg, err
+		}
+	}
+
+	var errs []error
+	for _, s := range settings {
+		if value := os.Getenv(s.env); value != "" {
+			errs = append(errs, s.set(value))
+		}
+	}
+	for _, v := range values {
+		if v.isSet {
+			errs = append(errs, v.setting.set(v.raw))
+		}
+	}
+	errs = append(errs, cfg.Validate())
+
+	return cfg, errors.Join(errs...)
+}
+
+func loadFile(path string, cfg *Config) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+	defer f.Close()
+
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("reading config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// setting is a single value of Config, with the environment variable that
+// sets it.
+type setting struct {
+	env   string
+	usage string
+	value reflect.Value
+}
+
+// settingsOf lists the settings of the struct v, whose environment variables
+// start with prefix.
+func settingsOf(v reflect.Value, prefix string) []setting {
+	settings := []setting{}
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		env := prefix + field.Tag.Get("env")
+		if field.Type.Kind() == reflect.Struct {
+			settings = append(settings, settingsOf(v.Field(i), env)...)
+			continue
+		}
+		settings = append(settings, setting{env: env, usage: field.Tag.Get("usage"), value: v.Field(i)})
+	}
+	return settings
+}
+
+func (s setting) flagName() string {
+	return strings.ToLower(strings.ReplaceAll(s.env, "_", "-"))
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+func (s setting) set(value string) error {
+	switch {
+	case s.value.Type() == durationType:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("%s must be a duration like 15m, got %q", s.env, value)
+		}
+		s.value.SetInt(int64(d))
+	case s.value.Kind() == reflect.Int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%s must be a number, got %q", s.env, value)
+		}
+		s.value.SetInt(int64(n))
+	case s.value.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%s must be true or false, got %q", s.env, value)
+		}
+		s.value.SetBool(b)
+	case s.value.Kind() == reflect.String:
+		s.value.SetString(value)
+	default:
+		panic("config: unsupported setting type " + s.value.Type().String())
+	}
+	return nil
+}
+
+// flagValue holds a flag until the file and the environment have been read,
+// so that it can override them.
+type flagValue struct {
+	setting
+	raw   string
+	isSet bool
+}
+
+func (f *flagValue) String() string {
+	// The flag package calls String on a zero flagValue to find out whether
+	// a default is worth printing.
+	if f == nil || !f.value.IsValid() {
+		return ""
+	}
+	return fmt.Sprint(f.value.Interface())
+}
+
+func (f *flagValue) Set(value string) error {
+	f.raw = value
+	f.isSet = true
+	return nil
+}
+
+func (f *flagValue) IsBoolFlag() bool {
+	return f.value.IsValid() && f.value.Kind() == reflect.Bool
+}
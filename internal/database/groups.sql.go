@@ -7,6 +7,7 @@ package database
 
 import (
 	"context"
+	"database/sql"
 
 	"github.com/google/uuid"
 )
@@ -40,3 +41,296 @@ func (q *Queries) CreateGroup(ctx context.Context, arg CreateGroupParams) (Group
 	)
 	return i, err
 }
+
+const deleteGroup = `-- name: DeleteGroup :exec
+DELETE FROM groups
+WHERE id = $1
+`
+
+func (q *Queries) DeleteGroup(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteGroup, id)
+	return err
+}
+
+const getGroupById = `-- name: GetGroupById :one
+SELECT id, name, created_at, updated_at, author_id FROM groups
+WHERE id = $1
+`
+
+func (q *Queries) GetGroupById(ctx context.Context, id uuid.UUID) (Group, error) {
+	row := q.db.QueryRowContext(ctx, getGroupById, id)
+	var i Group
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.AuthorID,
+	)
+	return i, err
+}
+
+const getGroupsOwnedOrAuthoredBy = `-- name: GetGroupsOwnedOrAuthoredBy :many
+SELECT id, name, created_at, updated_at, author_id FROM groups
+WHERE author_id = $1
+   OR id IN (SELECT group_id FROM group_members WHERE user_id = $1 AND role = 'owner')
+ORDER BY created_at ASC
+`
+
+func (q *Queries) GetGroupsOwnedOrAuthoredBy(ctx context.Context, authorID uuid.UUID) ([]Group, error) {
+	rows, err := q.db.QueryContext(ctx, getGroupsOwnedOrAuthoredBy, authorID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Group
+	for rows.Next() {
+		var i Group
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.AuthorID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listGroupsForUserByCreatedAt = `-- name: ListGroupsForUserByCreatedAt :many
+SELECT groups.id, groups.name, groups.created_at, groups.updated_at, groups.author_id FROM groups
+JOIN group_members ON group_members.group_id = groups.id
+WHERE group_members.user_id = $1
+  AND ($2::text IS NULL OR groups.name ILIKE $2 || '%')
+  AND ($3::timestamp IS NULL OR (groups.created_at, groups.id) > ($3, $4::uuid))
+ORDER BY groups.created_at ASC, groups.id ASC
+LIMIT $5
+`
+
+type ListGroupsForUserByCreatedAtParams struct {
+	UserID         uuid.UUID
+	NamePrefix     sql.NullString
+	AfterCreatedAt sql.NullTime
+	AfterID        uuid.NullUUID
+	Limit          int32
+}
+
+func (q *Queries) ListGroupsForUserByCreatedAt(ctx context.Context, arg ListGroupsForUserByCreatedAtParams) ([]Group, error) {
+	rows, err := q.db.QueryContext(ctx, listGroupsForUserByCreatedAt, arg.UserID, arg.NamePrefix, arg.AfterCreatedAt, arg.AfterID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Group
+	for rows.Next() {
+		var i Group
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.AuthorID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listGroupsForUserByCreatedAtDesc = `-- name: ListGroupsForUserByCreatedAtDesc :many
+SELECT groups.id, groups.name, groups.created_at, groups.updated_at, groups.author_id FROM groups
+JOIN group_members ON group_members.group_id = groups.id
+WHERE group_members.user_id = $1
+  AND ($2::text IS NULL OR groups.name ILIKE $2 || '%')
+  AND ($3::timestamp IS NULL OR (groups.created_at, groups.id) < ($3, $4::uuid))
+ORDER BY groups.created_at DESC, groups.id DESC
+LIMIT $5
+`
+
+type ListGroupsForUserByCreatedAtDescParams struct {
+	UserID         uuid.UUID
+	NamePrefix     sql.NullString
+	AfterCreatedAt sql.NullTime
+	AfterID        uuid.NullUUID
+	Limit          int32
+}
+
+func (q *Queries) ListGroupsForUserByCreatedAtDesc(ctx context.Context, arg ListGroupsForUserByCreatedAtDescParams) ([]Group, error) {
+	rows, err := q.db.QueryContext(ctx, listGroupsForUserByCreatedAtDesc, arg.UserID, arg.NamePrefix, arg.AfterCreatedAt, arg.AfterID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Group
+	for rows.Next() {
+		var i Group
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.AuthorID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listGroupsForUserByName = `-- name: ListGroupsForUserByName :many
+SELECT groups.id, groups.name, groups.created_at, groups.updated_at, groups.author_id FROM groups
+JOIN group_members ON group_members.group_id = groups.id
+WHERE group_members.user_id = $1
+  AND ($2::text IS NULL OR groups.name ILIKE $2 || '%')
+  AND ($3::text IS NULL OR (groups.name, groups.id) > ($3, $4::uuid))
+ORDER BY groups.name ASC, groups.id ASC
+LIMIT $5
+`
+
+type ListGroupsForUserByNameParams struct {
+	UserID     uuid.UUID
+	NamePrefix sql.NullString
+	AfterName  sql.NullString
+	AfterID    uuid.NullUUID
+	Limit      int32
+}
+
+func (q *Queries) ListGroupsForUserByName(ctx context.Context, arg ListGroupsForUserByNameParams) ([]Group, error) {
+	rows, err := q.db.QueryContext(ctx, listGroupsForUserByName, arg.UserID, arg.NamePrefix, arg.AfterName, arg.AfterID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Group
+	for rows.Next() {
+		var i Group
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.AuthorID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listGroupsForUserByNameDesc = `-- name: ListGroupsForUserByNameDesc :many
+SELECT groups.id, groups.name, groups.created_at, groups.updated_at, groups.author_id FROM groups
+JOIN group_members ON group_members.group_id = groups.id
+WHERE group_members.user_id = $1
+  AND ($2::text IS NULL OR groups.name ILIKE $2 || '%')
+  AND ($3::text IS NULL OR (groups.name, groups.id) < ($3, $4::uuid))
+ORDER BY groups.name DESC, groups.id DESC
+LIMIT $5
+`
+
+type ListGroupsForUserByNameDescParams struct {
+	UserID     uuid.UUID
+	NamePrefix sql.NullString
+	AfterName  sql.NullString
+	AfterID    uuid.NullUUID
+	Limit      int32
+}
+
+func (q *Queries) ListGroupsForUserByNameDesc(ctx context.Context, arg ListGroupsForUserByNameDescParams) ([]Group, error) {
+	rows, err := q.db.QueryContext(ctx, listGroupsForUserByNameDesc, arg.UserID, arg.NamePrefix, arg.AfterName, arg.AfterID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Group
+	for rows.Next() {
+		var i Group
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.AuthorID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const updateGroupAuthor = `-- name: UpdateGroupAuthor :exec
+UPDATE groups
+SET author_id = $2, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1
+`
+
+type UpdateGroupAuthorParams struct {
+	ID       uuid.UUID
+	AuthorID uuid.UUID
+}
+
+func (q *Queries) UpdateGroupAuthor(ctx context.Context, arg UpdateGroupAuthorParams) error {
+	_, err := q.db.ExecContext(ctx, updateGroupAuthor, arg.ID, arg.AuthorID)
+	return err
+}
+
+const updateGroupName = `-- name: UpdateGroupName :one
+UPDATE groups
+SET name = $2, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1
+RETURNING id, name, created_at, updated_at, author_id
+`
+
+type UpdateGroupNameParams struct {
+	ID   uuid.UUID
+	Name string
+}
+
+func (q *Queries) UpdateGroupName(ctx context.Context, arg UpdateGroupNameParams) (Group, error) {
+	row := q.db.QueryRowContext(ctx, updateGroupName, arg.ID, arg.Name)
+	var i Group
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.AuthorID,
+	)
+	return i, err
+}
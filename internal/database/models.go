@@ -11,6 +11,37 @@ import (
 	"github.com/google/uuid"
 )
 
+type ApiKey struct {
+	ID         uuid.UUID
+	UserID     uuid.UUID
+	Name       string
+	Prefix     string
+	KeyHash    string
+	Scopes     []string
+	ExpiresAt  sql.NullTime
+	LastUsedAt sql.NullTime
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+type AuditEvent struct {
+	ID        uuid.UUID
+	UserID    uuid.NullUUID
+	EventType string
+	IpAddress sql.NullString
+	Detail    sql.NullString
+	CreatedAt time.Time
+}
+
+type EmailVerificationToken struct {
+	TokenHash string
+	UserID    uuid.UUID
+	Email     string
+	ExpiresAt time.Time
+	UsedAt    sql.NullTime
+	CreatedAt time.Time
+}
+
 type Group struct {
 	ID        uuid.UUID
 	Name      string
@@ -19,19 +50,129 @@ type Group struct {
 	AuthorID  uuid.UUID
 }
 
-type RefreshToken struct {
-	Token     string
+type GroupInvite struct {
+	ID         uuid.UUID
+	GroupID    uuid.UUID
+	CreatedBy  uuid.UUID
+	TokenHash  string
+	Email      sql.NullString
+	Role       string
+	MaxUses    sql.NullInt32
+	Uses       int32
+	ExpiresAt  time.Time
+	RevokedAt  sql.NullTime
+	DeclinedAt sql.NullTime
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+type GroupMember struct {
+	GroupID   uuid.UUID
+	UserID    uuid.UUID
+	Role      string
 	CreatedAt time.Time
 	UpdatedAt time.Time
+}
+
+type LoginAttempt struct {
+	Key           string
+	Failures      int32
+	LastFailureAt time.Time
+}
+
+type MfaChallenge struct {
+	TokenHash        string
+	UserID           uuid.UUID
+	DeviceLabel      sql.NullString
+	Attempts         int32
+	ExpiresAt        time.Time
+	UsedAt           sql.NullTime
+	CreatedAt        time.Time
+	ExpiresInSeconds int64
+}
+
+type MfaRecoveryCode struct {
+	ID        uuid.UUID
 	UserID    uuid.UUID
+	CodeHash  string
+	UsedAt    sql.NullTime
+	CreatedAt time.Time
+}
+
+type OauthCode struct {
+	CodeHash  string
+	UserID    uuid.UUID
+	Purpose   string
 	ExpiresAt time.Time
-	RevokedAt sql.NullTime
+	UsedAt    sql.NullTime
+	CreatedAt time.Time
+}
+
+type PasswordResetToken struct {
+	TokenHash string
+	UserID    uuid.UUID
+	ExpiresAt time.Time
+	UsedAt    sql.NullTime
+	CreatedAt time.Time
+}
+
+type RefreshToken struct {
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	UserID      uuid.UUID
+	ExpiresAt   time.Time
+	RevokedAt   sql.NullTime
+	FamilyID    uuid.UUID
+	ReplacedBy  sql.NullString
+	ID          uuid.UUID
+	TokenHash   string
+	UserAgent   sql.NullString
+	IpAddress   sql.NullString
+	DeviceLabel sql.NullString
+	LastUsedAt  sql.NullTime
+}
+
+type RevokedToken struct {
+	ID        int64
+	Kind      string
+	Value     string
+	RevokedAt time.Time
+	ExpiresAt time.Time
+	CreatedAt time.Time
 }
 
 type User struct {
-	ID           uuid.UUID
-	Email        string
+	ID                    uuid.UUID
+	Email                 string
+	CreatedAt             time.Time
+	UpdatedAt             time.Time
+	PasswordHash          string
+	EmailVerifiedAt       sql.NullTime
+	Role                  string
+	DisabledAt            sql.NullTime
+	PasswordResetRequired bool
+	DisplayName           sql.NullString
+	AvatarUrl             sql.NullString
+	Locale                sql.NullString
+	Timezone              sql.NullString
+	DeletedAt             sql.NullTime
+}
+
+type UserIdentity struct {
+	ID             uuid.UUID
+	UserID         uuid.UUID
+	Provider       string
+	ProviderUserID string
+	Email          sql.NullString
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+type UserTotp struct {
+	UserID       uuid.UUID
+	Secret       string
+	ConfirmedAt  sql.NullTime
+	LastUsedStep int64
 	CreatedAt    time.Time
 	UpdatedAt    time.Time
-	PasswordHash string
 }
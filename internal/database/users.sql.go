@@ -7,10 +7,23 @@ package database
 
 import (
 	"context"
+	"database/sql"
 
 	"github.com/google/uuid"
 )
 
+const countAdmins = `-- name: CountAdmins :one
+SELECT COUNT(*) FROM users
+WHERE role = 'admin'
+`
+
+func (q *Queries) CountAdmins(ctx context.Context) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countAdmins)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createUser = `-- name: CreateUser :one
 INSERT INTO users (id, created_at, updated_at, email, password_hash)
 VALUES (
@@ -20,7 +33,7 @@ VALUES (
     $1,
     $2
 )
-RETURNING id, email, created_at, updated_at, password_hash
+RETURNING id, email, created_at, updated_at, password_hash, email_verified_at, role, disabled_at, password_reset_required, display_name, avatar_url, locale, timezone, deleted_at
 `
 
 type CreateUserParams struct {
@@ -37,6 +50,48 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.PasswordHash,
+		&i.EmailVerifiedAt,
+		&i.Role,
+		&i.DisabledAt,
+		&i.PasswordResetRequired,
+		&i.DisplayName,
+		&i.AvatarUrl,
+		&i.Locale,
+		&i.Timezone,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const createUserWithoutPassword = `-- name: CreateUserWithoutPassword :one
+INSERT INTO users (email, email_verified_at)
+VALUES ($1, $2)
+RETURNING id, email, created_at, updated_at, password_hash, email_verified_at, role, disabled_at, password_reset_required, display_name, avatar_url, locale, timezone, deleted_at
+`
+
+type CreateUserWithoutPasswordParams struct {
+	Email           string
+	EmailVerifiedAt sql.NullTime
+}
+
+func (q *Queries) CreateUserWithoutPassword(ctx context.Context, arg CreateUserWithoutPasswordParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, createUserWithoutPassword, arg.Email, arg.EmailVerifiedAt)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.PasswordHash,
+		&i.EmailVerifiedAt,
+		&i.Role,
+		&i.DisabledAt,
+		&i.PasswordResetRequired,
+		&i.DisplayName,
+		&i.AvatarUrl,
+		&i.Locale,
+		&i.Timezone,
+		&i.DeletedAt,
 	)
 	return i, err
 }
@@ -50,8 +105,18 @@ func (q *Queries) DeleteAllUsers(ctx context.Context) error {
 	return err
 }
 
+const deleteUser = `-- name: DeleteUser :exec
+DELETE FROM users
+WHERE id = $1
+`
+
+func (q *Queries) DeleteUser(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteUser, id)
+	return err
+}
+
 const getUserByEmail = `-- name: GetUserByEmail :one
-SELECT id, email, created_at, updated_at, password_hash FROM users
+SELECT id, email, created_at, updated_at, password_hash, email_verified_at, role, disabled_at, password_reset_required, display_name, avatar_url, locale, timezone, deleted_at FROM users
 WHERE email = $1
 `
 
@@ -64,12 +129,21 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.PasswordHash,
+		&i.EmailVerifiedAt,
+		&i.Role,
+		&i.DisabledAt,
+		&i.PasswordResetRequired,
+		&i.DisplayName,
+		&i.AvatarUrl,
+		&i.Locale,
+		&i.Timezone,
+		&i.DeletedAt,
 	)
 	return i, err
 }
 
 const getUserById = `-- name: GetUserById :one
-SELECT id, email, created_at, updated_at, password_hash FROM users
+SELECT id, email, created_at, updated_at, password_hash, email_verified_at, role, disabled_at, password_reset_required, display_name, avatar_url, locale, timezone, deleted_at FROM users
 WHERE id = $1
 `
 
@@ -82,17 +156,253 @@ func (q *Queries) GetUserById(ctx context.Context, id uuid.UUID) (User, error) {
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.PasswordHash,
+		&i.EmailVerifiedAt,
+		&i.Role,
+		&i.DisabledAt,
+		&i.PasswordResetRequired,
+		&i.DisplayName,
+		&i.AvatarUrl,
+		&i.Locale,
+		&i.Timezone,
+		&i.DeletedAt,
 	)
 	return i, err
 }
 
-const getUsers = `-- name: GetUsers :many
-SELECT id, email, created_at, updated_at, password_hash FROM users
-ORDER BY created_at ASC
+const getUsersDeletedBefore = `-- name: GetUsersDeletedBefore :many
+SELECT id, email, created_at, updated_at, password_hash, email_verified_at, role, disabled_at, password_reset_required, display_name, avatar_url, locale, timezone, deleted_at FROM users
+WHERE deleted_at < $1
+ORDER BY deleted_at ASC
+`
+
+func (q *Queries) GetUsersDeletedBefore(ctx context.Context, deletedAt sql.NullTime) ([]User, error) {
+	rows, err := q.db.QueryContext(ctx, getUsersDeletedBefore, deletedAt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []User
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.Email,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.PasswordHash,
+			&i.EmailVerifiedAt,
+			&i.Role,
+			&i.DisabledAt,
+			&i.PasswordResetRequired,
+			&i.DisplayName,
+			&i.AvatarUrl,
+			&i.Locale,
+			&i.Timezone,
+			&i.DeletedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUsersByCreatedAt = `-- name: ListUsersByCreatedAt :many
+SELECT id, email, created_at, updated_at, password_hash, email_verified_at, role, disabled_at, password_reset_required, display_name, avatar_url, locale, timezone, deleted_at FROM users
+WHERE ($1::text IS NULL OR email ILIKE $1 || '%')
+  AND ($2::timestamp IS NULL OR created_at >= $2)
+  AND ($3::timestamp IS NULL OR created_at < $3)
+  AND ($4::timestamp IS NULL OR (created_at, id) > ($4, $5::uuid))
+ORDER BY created_at ASC, id ASC
+LIMIT $6
+`
+
+type ListUsersByCreatedAtParams struct {
+	EmailPrefix    sql.NullString
+	CreatedAfter   sql.NullTime
+	CreatedBefore  sql.NullTime
+	AfterCreatedAt sql.NullTime
+	AfterID        uuid.NullUUID
+	Limit          int32
+}
+
+func (q *Queries) ListUsersByCreatedAt(ctx context.Context, arg ListUsersByCreatedAtParams) ([]User, error) {
+	rows, err := q.db.QueryContext(ctx, listUsersByCreatedAt, arg.EmailPrefix, arg.CreatedAfter, arg.CreatedBefore, arg.AfterCreatedAt, arg.AfterID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []User
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.Email,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.PasswordHash,
+			&i.EmailVerifiedAt,
+			&i.Role,
+			&i.DisabledAt,
+			&i.PasswordResetRequired,
+			&i.DisplayName,
+			&i.AvatarUrl,
+			&i.Locale,
+			&i.Timezone,
+			&i.DeletedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUsersByCreatedAtDesc = `-- name: ListUsersByCreatedAtDesc :many
+SELECT id, email, created_at, updated_at, password_hash, email_verified_at, role, disabled_at, password_reset_required, display_name, avatar_url, locale, timezone, deleted_at FROM users
+WHERE ($1::text IS NULL OR email ILIKE $1 || '%')
+  AND ($2::timestamp IS NULL OR created_at >= $2)
+  AND ($3::timestamp IS NULL OR created_at < $3)
+  AND ($4::timestamp IS NULL OR (created_at, id) < ($4, $5::uuid))
+ORDER BY created_at DESC, id DESC
+LIMIT $6
+`
+
+type ListUsersByCreatedAtDescParams struct {
+	EmailPrefix    sql.NullString
+	CreatedAfter   sql.NullTime
+	CreatedBefore  sql.NullTime
+	AfterCreatedAt sql.NullTime
+	AfterID        uuid.NullUUID
+	Limit          int32
+}
+
+func (q *Queries) ListUsersByCreatedAtDesc(ctx context.Context, arg ListUsersByCreatedAtDescParams) ([]User, error) {
+	rows, err := q.db.QueryContext(ctx, listUsersByCreatedAtDesc, arg.EmailPrefix, arg.CreatedAfter, arg.CreatedBefore, arg.AfterCreatedAt, arg.AfterID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []User
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.Email,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.PasswordHash,
+			&i.EmailVerifiedAt,
+			&i.Role,
+			&i.DisabledAt,
+			&i.PasswordResetRequired,
+			&i.DisplayName,
+			&i.AvatarUrl,
+			&i.Locale,
+			&i.Timezone,
+			&i.DeletedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUsersByEmail = `-- name: ListUsersByEmail :many
+SELECT id, email, created_at, updated_at, password_hash, email_verified_at, role, disabled_at, password_reset_required, display_name, avatar_url, locale, timezone, deleted_at FROM users
+WHERE ($1::text IS NULL OR email ILIKE $1 || '%')
+  AND ($2::timestamp IS NULL OR created_at >= $2)
+  AND ($3::timestamp IS NULL OR created_at < $3)
+  AND ($4::text IS NULL OR email > $4)
+ORDER BY email ASC
+LIMIT $5
+`
+
+type ListUsersByEmailParams struct {
+	EmailPrefix   sql.NullString
+	CreatedAfter  sql.NullTime
+	CreatedBefore sql.NullTime
+	AfterEmail    sql.NullString
+	Limit         int32
+}
+
+func (q *Queries) ListUsersByEmail(ctx context.Context, arg ListUsersByEmailParams) ([]User, error) {
+	rows, err := q.db.QueryContext(ctx, listUsersByEmail, arg.EmailPrefix, arg.CreatedAfter, arg.CreatedBefore, arg.AfterEmail, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []User
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.Email,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.PasswordHash,
+			&i.EmailVerifiedAt,
+			&i.Role,
+			&i.DisabledAt,
+			&i.PasswordResetRequired,
+			&i.DisplayName,
+			&i.AvatarUrl,
+			&i.Locale,
+			&i.Timezone,
+			&i.DeletedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUsersByEmailDesc = `-- name: ListUsersByEmailDesc :many
+SELECT id, email, created_at, updated_at, password_hash, email_verified_at, role, disabled_at, password_reset_required, display_name, avatar_url, locale, timezone, deleted_at FROM users
+WHERE ($1::text IS NULL OR email ILIKE $1 || '%')
+  AND ($2::timestamp IS NULL OR created_at >= $2)
+  AND ($3::timestamp IS NULL OR created_at < $3)
+  AND ($4::text IS NULL OR email < $4)
+ORDER BY email DESC
+LIMIT $5
 `
 
-func (q *Queries) GetUsers(ctx context.Context) ([]User, error) {
-	rows, err := q.db.QueryContext(ctx, getUsers)
+type ListUsersByEmailDescParams struct {
+	EmailPrefix   sql.NullString
+	CreatedAfter  sql.NullTime
+	CreatedBefore sql.NullTime
+	AfterEmail    sql.NullString
+	Limit         int32
+}
+
+func (q *Queries) ListUsersByEmailDesc(ctx context.Context, arg ListUsersByEmailDescParams) ([]User, error) {
+	rows, err := q.db.QueryContext(ctx, listUsersByEmailDesc, arg.EmailPrefix, arg.CreatedAfter, arg.CreatedBefore, arg.AfterEmail, arg.Limit)
 	if err != nil {
 		return nil, err
 	}
@@ -106,6 +416,15 @@ func (q *Queries) GetUsers(ctx context.Context) ([]User, error) {
 			&i.CreatedAt,
 			&i.UpdatedAt,
 			&i.PasswordHash,
+			&i.EmailVerifiedAt,
+			&i.Role,
+			&i.DisabledAt,
+			&i.PasswordResetRequired,
+			&i.DisplayName,
+			&i.AvatarUrl,
+			&i.Locale,
+			&i.Timezone,
+			&i.DeletedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -120,19 +439,144 @@ func (q *Queries) GetUsers(ctx context.Context) ([]User, error) {
 	return items, nil
 }
 
-const updateUser = `-- name: UpdateUser :exec
+const lockUserById = `-- name: LockUserById :one
+SELECT id, email, created_at, updated_at, password_hash, email_verified_at, role, disabled_at, password_reset_required, display_name, avatar_url, locale, timezone, deleted_at FROM users
+WHERE id = $1
+FOR UPDATE
+`
+
+func (q *Queries) LockUserById(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, lockUserById, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.PasswordHash,
+		&i.EmailVerifiedAt,
+		&i.Role,
+		&i.DisabledAt,
+		&i.PasswordResetRequired,
+		&i.DisplayName,
+		&i.AvatarUrl,
+		&i.Locale,
+		&i.Timezone,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const requireUserPasswordReset = `-- name: RequireUserPasswordReset :exec
+UPDATE users
+SET password_reset_required = true, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1
+`
+
+func (q *Queries) RequireUserPasswordReset(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, requireUserPasswordReset, id)
+	return err
+}
+
+const updateUserDeletedAt = `-- name: UpdateUserDeletedAt :exec
+UPDATE users
+SET deleted_at = $2, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1
+`
+
+type UpdateUserDeletedAtParams struct {
+	ID        uuid.UUID
+	DeletedAt sql.NullTime
+}
+
+func (q *Queries) UpdateUserDeletedAt(ctx context.Context, arg UpdateUserDeletedAtParams) error {
+	_, err := q.db.ExecContext(ctx, updateUserDeletedAt, arg.ID, arg.DeletedAt)
+	return err
+}
+
+const updateUserDisabledAt = `-- name: UpdateUserDisabledAt :exec
+UPDATE users
+SET disabled_at = $2, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1
+`
+
+type UpdateUserDisabledAtParams struct {
+	ID         uuid.UUID
+	DisabledAt sql.NullTime
+}
+
+func (q *Queries) UpdateUserDisabledAt(ctx context.Context, arg UpdateUserDisabledAtParams) error {
+	_, err := q.db.ExecContext(ctx, updateUserDisabledAt, arg.ID, arg.DisabledAt)
+	return err
+}
+
+const updateUserPassword = `-- name: UpdateUserPassword :exec
 UPDATE users
-SET email = $2, password_hash = $3
+SET password_hash = $2, password_reset_required = false, updated_at = CURRENT_TIMESTAMP
 WHERE id = $1
 `
 
-type UpdateUserParams struct {
+type UpdateUserPasswordParams struct {
 	ID           uuid.UUID
-	Email        string
 	PasswordHash string
 }
 
-func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) error {
-	_, err := q.db.ExecContext(ctx, updateUser, arg.ID, arg.Email, arg.PasswordHash)
+func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) error {
+	_, err := q.db.ExecContext(ctx, updateUserPassword, arg.ID, arg.PasswordHash)
+	return err
+}
+
+const updateUserProfile = `-- name: UpdateUserProfile :one
+UPDATE users
+SET email = $2, email_verified_at = $3, display_name = $4, avatar_url = $5, locale = $6, timezone = $7, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1
+RETURNING id, email, created_at, updated_at, password_hash, email_verified_at, role, disabled_at, password_reset_required, display_name, avatar_url, locale, timezone, deleted_at
+`
+
+type UpdateUserProfileParams struct {
+	ID              uuid.UUID
+	Email           string
+	EmailVerifiedAt sql.NullTime
+	DisplayName     sql.NullString
+	AvatarUrl       sql.NullString
+	Locale          sql.NullString
+	Timezone        sql.NullString
+}
+
+func (q *Queries) UpdateUserProfile(ctx context.Context, arg UpdateUserProfileParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUserProfile, arg.ID, arg.Email, arg.EmailVerifiedAt, arg.DisplayName, arg.AvatarUrl, arg.Locale, arg.Timezone)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.PasswordHash,
+		&i.EmailVerifiedAt,
+		&i.Role,
+		&i.DisabledAt,
+		&i.PasswordResetRequired,
+		&i.DisplayName,
+		&i.AvatarUrl,
+		&i.Locale,
+		&i.Timezone,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const updateUserRole = `-- name: UpdateUserRole :exec
+UPDATE users
+SET role = $2, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1
+`
+
+type UpdateUserRoleParams struct {
+	ID   uuid.UUID
+	Role string
+}
+
+func (q *Queries) UpdateUserRole(ctx context.Context, arg UpdateUserRoleParams) error {
+	_, err := q.db.ExecContext(ctx, updateUserRole, arg.ID, arg.Role)
 	return err
 }
@@ -0,0 +1,188 @@
+// Package denylist revokes access tokens before they expire. Revocations are
+// kept in memory so checking a token costs a few map lookups, and can be
+// shared between instances through a Store.
+package denylist
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// Kind is what a revocation matches tokens by.
+type Kind string
+
+const (
+	// KindToken revokes the single token with the given jti.
+	KindToken Kind = "jti"
+	// KindSession revokes every token issued for a session.
+	KindSession Kind = "sid"
+	// KindUser revokes every token issued to a user before the revocation.
+	KindUser Kind = "user"
+)
+
+type Entry struct {
+	ID        int64
+	Kind      Kind
+	Value     string
+	RevokedAt time.Time
+	// ExpiresAt is when every token the entry matches has expired, so the
+	// entry can be forgotten.
+	ExpiresAt time.Time
+	// AddedAt is when the store added the entry, by the store's clock.
+	AddedAt time.Time
+}
+
+// Store shares revocations between instances.
+type Store interface {
+	Add(ctx context.Context, entry Entry) error
+	// Since returns the unexpired entries added after the given time, in the
+	// order they were added.
+	Since(ctx context.Context, since time.Time, now time.Time) ([]Entry, error)
+	// Prune deletes entries that expired before the given time.
+	Prune(ctx context.Context, before time.Time) error
+}
+
+type key struct {
+	kind  Kind
+	value string
+}
+
+type Denylist struct {
+	store Store
+
+	mu      sync.RWMutex
+	entries map[key]Entry
+	// lastAdded is when the store added the newest entry synced so far.
+	lastAdded time.Time
+}
+
+// syncOverlap is how far back each sync reads again. Entries become visible
+// when their transaction commits, which can be after a later entry was
+// already synced, so the last stretch before the newest entry is read again
+// to pick up any that showed up late. Entries read twice are harmless, add
+// keeps the one it already has.
+const syncOverlap = time.Minute
+
+// New returns a denylist backed by store. A nil store keeps revocations in
+// this process only.
+func New(store Store) *Denylist {
+	return &Denylist{store: store, entries: map[key]Entry{}}
+}
+
+// Revoke denies tokens matching kind and value until expiresAt, which should
+// be no earlier than the expiry of the longest lived token it matches.
+func (d *Denylist) Revoke(ctx context.Context, kind Kind, value string, expiresAt time.Time) error {
+	entry := Entry{Kind: kind, Value: value, RevokedAt: time.Now(), ExpiresAt: expiresAt}
+
+	// This instance denies the tokens even if the store can't take the entry,
+	// so the caller's error only means other instances may not.
+	d.mu.Lock()
+	d.add(entry)
+	d.mu.Unlock()
+
+	if d.store == nil {
+		return nil
+	}
+	return d.store.Add(ctx, entry)
+}
+
+// IsRevoked reports whether a token with the given ID, session, subject and
+// issue time has been revoked.
+func (d *Denylist) IsRevoked(jti, sessionID, userID string, issuedAt time.Time) bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if _, ok := d.entries[key{KindToken, jti}]; ok && jti != "" {
+		return true
+	}
+	if _, ok := d.entries[key{KindSession, sessionID}]; ok && sessionID != "" {
+		return true
+	}
+
+	entry, ok := d.entries[key{KindUser, userID}]
+	if !ok {
+		return false
+	}
+	// Issue times only have second precision. Comparing whole seconds lets
+	// a token issued in the same second as the revocation through, rather
+	// than rejecting the new login that often follows right after it.
+	return issuedAt.Before(entry.RevokedAt.Truncate(time.Second))
+}
+
+// Sync loads entries other instances added to the store since the last sync.
+func (d *Denylist) Sync(ctx context.Context) error {
+	if d.store == nil {
+		return nil
+	}
+
+	d.mu.RLock()
+	since := d.lastAdded
+	d.mu.RUnlock()
+	if !since.IsZero() {
+		since = since.Add(-syncOverlap)
+	}
+
+	entries, err := d.store.Since(ctx, since, time.Now())
+	if err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, entry := range entries {
+		d.add(entry)
+		if entry.AddedAt.After(d.lastAdded) {
+			d.lastAdded = entry.AddedAt
+		}
+	}
+	return nil
+}
+
+// Run syncs with the store and forgets expired entries every interval until
+// ctx is done.
+func (d *Denylist) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := d.Sync(ctx); err != nil {
+			log.Printf("Error syncing token denylist: %v", err)
+		}
+		d.prune(ctx, time.Now())
+	}
+}
+
+func (d *Denylist) prune(ctx context.Context, now time.Time) {
+	d.mu.Lock()
+	for k, entry := range d.entries {
+		if entry.ExpiresAt.Before(now) {
+			delete(d.entries, k)
+		}
+	}
+	d.mu.Unlock()
+
+	if d.store != nil {
+		if err := d.store.Prune(ctx, now); err != nil {
+			log.Printf("Error pruning token denylist: %v", err)
+		}
+	}
+}
+
+// add stores entry, keeping the latest revocation when a key is revoked more
+// than once. d.mu must be held.
+func (d *Denylist) add(entry Entry) {
+	k := key{entry.Kind, entry.Value}
+	if existing, ok := d.entries[k]; ok && existing.RevokedAt.After(entry.RevokedAt) {
+		return
+	}
+	d.entries[k] = entry
+}
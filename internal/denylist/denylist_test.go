@@ -0,0 +1,161 @@
+package denylist
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakeStore keeps entries in a slice and remembers what Since was asked for.
+type fakeStore struct {
+	entries []Entry
+	since   []time.Time
+	addErr  error
+}
+
+func (s *fakeStore) Add(ctx context.Context, entry Entry) error {
+	if s.addErr != nil {
+		return s.addErr
+	}
+	entry.AddedAt = time.Now()
+	s.entries = append(s.entries, entry)
+	return nil
+}
+
+func (s *fakeStore) Since(ctx context.Context, since time.Time, now time.Time) ([]Entry, error) {
+	s.since = append(s.since, since)
+
+	var entries []Entry
+	for _, entry := range s.entries {
+		if entry.AddedAt.After(since) && entry.ExpiresAt.After(now) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+func (s *fakeStore) Prune(ctx context.Context, before time.Time) error {
+	return nil
+}
+
+func TestIsRevoked(t *testing.T) {
+	revokedAt := time.Date(2025, 6, 1, 12, 0, 0, 600_000_000, time.UTC)
+	expiresAt := revokedAt.Add(time.Hour)
+
+	d := New(nil)
+	d.add(Entry{Kind: KindToken, Value: "jti-1", RevokedAt: revokedAt, ExpiresAt: expiresAt})
+	d.add(Entry{Kind: KindSession, Value: "sid-1", RevokedAt: revokedAt, ExpiresAt: expiresAt})
+	d.add(Entry{Kind: KindUser, Value: "user-1", RevokedAt: revokedAt, ExpiresAt: expiresAt})
+
+	tests := []struct {
+		name      string
+		jti       string
+		sessionID string
+		userID    string
+		issuedAt  time.Time
+		want      bool
+	}{
+		{name: "revoked token", jti: "jti-1", issuedAt: revokedAt.Add(time.Minute), want: true},
+		{name: "revoked session", jti: "jti-2", sessionID: "sid-1", issuedAt: revokedAt.Add(time.Minute), want: true},
+		{name: "issued a second before", userID: "user-1", issuedAt: revokedAt.Add(-time.Second).Truncate(time.Second), want: true},
+		// Issue times are whole seconds, so a token issued later in the
+		// same second looks as if it was issued before the revocation.
+		{name: "issued in the same second", userID: "user-1", issuedAt: revokedAt.Truncate(time.Second)},
+		{name: "issued after", userID: "user-1", issuedAt: revokedAt.Add(time.Second).Truncate(time.Second)},
+		{name: "other user", userID: "user-2", issuedAt: revokedAt.Add(-time.Hour)},
+		{name: "no IDs", issuedAt: revokedAt.Add(-time.Hour)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := d.IsRevoked(tt.jti, tt.sessionID, tt.userID, tt.issuedAt); got != tt.want {
+				t.Errorf("IsRevoked() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddKeepsLatestRevocation(t *testing.T) {
+	now := time.Now()
+	earlier := Entry{Kind: KindUser, Value: "u", RevokedAt: now.Add(-time.Hour), ExpiresAt: now.Add(time.Hour)}
+	later := Entry{Kind: KindUser, Value: "u", RevokedAt: now, ExpiresAt: now.Add(time.Hour)}
+
+	tests := []struct {
+		name  string
+		order []Entry
+	}{
+		{"in order", []Entry{earlier, later}},
+		{"out of order", []Entry{later, earlier}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := New(nil)
+			for _, entry := range tt.order {
+				d.add(entry)
+			}
+			if got := d.entries[key{KindUser, "u"}].RevokedAt; !got.Equal(later.RevokedAt) {
+				t.Errorf("RevokedAt = %v, want %v", got, later.RevokedAt)
+			}
+		})
+	}
+}
+
+func TestSync(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	store := &fakeStore{}
+	d := New(store)
+
+	newest := now.Add(-time.Second)
+	store.entries = []Entry{
+		{Kind: KindToken, Value: "a", AddedAt: now.Add(-time.Hour), ExpiresAt: now.Add(time.Hour)},
+		{Kind: KindToken, Value: "b", AddedAt: newest, ExpiresAt: now.Add(time.Hour)},
+		{Kind: KindToken, Value: "expired", AddedAt: newest, ExpiresAt: now.Add(-time.Minute)},
+	}
+	if err := d.Sync(ctx); err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+	if !store.since[0].IsZero() {
+		t.Errorf("first Sync() read since %v, want the beginning", store.since[0])
+	}
+	if !d.lastAdded.Equal(newest) {
+		t.Errorf("lastAdded = %v, want %v", d.lastAdded, newest)
+	}
+
+	// An entry whose transaction committed late shows up with an earlier
+	// AddedAt than one already synced.
+	store.entries = append(store.entries, Entry{Kind: KindToken, Value: "late", AddedAt: newest.Add(-time.Second * 10), ExpiresAt: now.Add(time.Hour)})
+	if err := d.Sync(ctx); err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+	if want := newest.Add(-syncOverlap); !store.since[1].Equal(want) {
+		t.Errorf("second Sync() read since %v, want %v", store.since[1], want)
+	}
+	if !d.lastAdded.Equal(newest) {
+		t.Errorf("lastAdded = %v after a late entry, want %v", d.lastAdded, newest)
+	}
+
+	for _, value := range []string{"a", "b", "late"} {
+		if !d.IsRevoked(value, "", "", now) {
+			t.Errorf("token %s isn't revoked after Sync()", value)
+		}
+	}
+	if d.IsRevoked("expired", "", "", now) {
+		t.Error("expired entry was synced")
+	}
+}
+
+func TestRevokeWhenStoreFails(t *testing.T) {
+	storeErr := errors.New("store is down")
+	d := New(&fakeStore{addErr: storeErr})
+
+	err := d.Revoke(context.Background(), KindSession, "sid", time.Now().Add(time.Hour))
+	if !errors.Is(err, storeErr) {
+		t.Errorf("Revoke() error = %v, want %v", err, storeErr)
+	}
+	if !d.IsRevoked("", "sid", "", time.Now()) {
+		t.Error("session isn't revoked on this instance after the store failed")
+	}
+}
@@ -0,0 +1,53 @@
+package denylist
+
+import (
+	"context"
+	"time"
+
+	"github.com/potom-dev/backend/internal/database"
+)
+
+// PostgresStore keeps revocations in the revoked_tokens table.
+type PostgresStore struct {
+	db *database.Queries
+}
+
+func NewPostgresStore(db *database.Queries) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+func (s *PostgresStore) Add(ctx context.Context, entry Entry) error {
+	return s.db.CreateRevokedToken(ctx, database.CreateRevokedTokenParams{
+		Kind:      string(entry.Kind),
+		Value:     entry.Value,
+		RevokedAt: entry.RevokedAt,
+		ExpiresAt: entry.ExpiresAt,
+	})
+}
+
+func (s *PostgresStore) Since(ctx context.Context, since time.Time, now time.Time) ([]Entry, error) {
+	rows, err := s.db.GetRevokedTokensSince(ctx, database.GetRevokedTokensSinceParams{
+		CreatedAt: since,
+		ExpiresAt: now,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]Entry, 0, len(rows))
+	for _, row := range rows {
+		entries = append(entries, Entry{
+			ID:        row.ID,
+			Kind:      Kind(row.Kind),
+			Value:     row.Value,
+			RevokedAt: row.RevokedAt,
+			ExpiresAt: row.ExpiresAt,
+			AddedAt:   row.CreatedAt,
+		})
+	}
+	return entries, nil
+}
+
+func (s *PostgresStore) Prune(ctx context.Context, before time.Time) error {
+	return s.db.DeleteExpiredRevokedTokens(ctx, before)
+}
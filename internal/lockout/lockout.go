@@ -0,0 +1,177 @@
+// Package lockout slows down password guessing by tracking failed attempts
+// per key (an account, an IP address) and making callers wait longer after
+// each failure, up to a temporary lockout.
+package lockout
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Attempts is the failure history of one key.
+type Attempts struct {
+	Failures    int
+	LastFailure time.Time
+}
+
+// Store keeps attempts. MemoryStore works for a single instance, PostgresStore
+// shares attempts between all instances using the same database.
+type Store interface {
+	// Update replaces the attempts for key with what fn returns, without any
+	// other update of key in between, and returns them. fn gets zero
+	// Attempts if there are none.
+	Update(ctx context.Context, key string, fn func(Attempts) Attempts) (Attempts, error)
+	// Reset forgets all attempts for key.
+	Reset(ctx context.Context, key string) error
+	// Prune forgets every key whose last failure was before the given time.
+	Prune(ctx context.Context, before time.Time) error
+}
+
+// Policy decides how long a key has to wait after a number of failures.
+type Policy struct {
+	// FreeAttempts is the number of failures allowed without any delay.
+	FreeAttempts int
+	// BaseDelay is the delay after the first failure past FreeAttempts. It
+	// doubles with every further failure, up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// LockoutThreshold is the number of failures after which the key is
+	// locked out for LockoutDuration.
+	LockoutThreshold int
+	LockoutDuration  time.Duration
+	// Window is how long failures are remembered. A failure after a quiet
+	// period longer than Window starts counting from one again.
+	Window time.Duration
+}
+
+// Delay returns how long to wait after the given number of failures.
+func (p Policy) Delay(failures int) time.Duration {
+	if failures >= p.LockoutThreshold {
+		return p.LockoutDuration
+	}
+	if failures <= p.FreeAttempts {
+		return 0
+	}
+
+	delay := p.BaseDelay
+	for i := p.FreeAttempts + 1; i < failures && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, p.MaxDelay)
+}
+
+const pruneInterval = time.Minute * 10
+
+type Limiter struct {
+	store  Store
+	policy Policy
+
+	mu         sync.Mutex
+	lastPruned time.Time
+}
+
+func NewLimiter(store Store, policy Policy) *Limiter {
+	return &Limiter{store: store, policy: policy}
+}
+
+// Reservation is an attempt taken for a key before it is checked. It counts
+// as a failure unless it is released.
+type Reservation struct {
+	limiter  *Limiter
+	key      string
+	failures int
+	// reservedAt is when the attempt was taken, and lastFailure the time of
+	// the failure before it, which Release puts back.
+	reservedAt  time.Time
+	lastFailure time.Time
+}
+
+// Reserve takes an attempt for key, or returns how long key has to wait
+// before its next attempt instead. Checking the limit and counting the
+// attempt happen in one store update, so that parallel attempts can't all
+// get in before the first of them fails.
+func (l *Limiter) Reserve(ctx context.Context, key string) (*Reservation, time.Duration, error) {
+	now := time.Now()
+	l.prune(ctx, now)
+
+	// Postgres keeps microseconds, and Release compares against what it
+	// stored.
+	reservedAt := now.Truncate(time.Microsecond)
+
+	var wait time.Duration
+	var lastFailure time.Time
+	attempts, err := l.store.Update(ctx, key, func(attempts Attempts) Attempts {
+		if attempts.Failures > 0 && now.Sub(attempts.LastFailure) > l.policy.Window {
+			attempts = Attempts{}
+		}
+
+		wait = 0
+		if attempts.Failures > 0 {
+			wait = max(attempts.LastFailure.Add(l.policy.Delay(attempts.Failures)).Sub(now), 0)
+		}
+		if wait > 0 {
+			return attempts
+		}
+
+		// The attempt counts as a failure until it is released, so that
+		// parallel attempts wait for it as well.
+		lastFailure = attempts.LastFailure
+		return Attempts{Failures: attempts.Failures + 1, LastFailure: reservedAt}
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	if wait > 0 {
+		return nil, wait, nil
+	}
+
+	return &Reservation{
+		limiter:     l,
+		key:         key,
+		failures:    attempts.Failures,
+		reservedAt:  reservedAt,
+		lastFailure: lastFailure,
+	}, 0, nil
+}
+
+// LockedOut reports whether failing the reserved attempt locks its key out,
+// for the first time or again after an earlier lockout ran out.
+func (r *Reservation) LockedOut() bool {
+	return r.failures >= r.limiter.policy.LockoutThreshold
+}
+
+// Release gives back a reserved attempt that succeeded, along with the time
+// of the failure before it, so that successes don't keep old failures inside
+// the window. A failure reserved after this attempt keeps its own time.
+func (r *Reservation) Release(ctx context.Context) error {
+	_, err := r.limiter.store.Update(ctx, r.key, func(attempts Attempts) Attempts {
+		attempts.Failures = max(attempts.Failures-1, 0)
+		if !attempts.LastFailure.After(r.reservedAt) {
+			attempts.LastFailure = r.lastFailure
+		}
+		return attempts
+	})
+	return err
+}
+
+// Reset forgets the failures of key, e.g. after a successful login.
+func (l *Limiter) Reset(ctx context.Context, key string) error {
+	return l.store.Reset(ctx, key)
+}
+
+// prune drops expired keys from the store every now and then, so that
+// guesses against made up accounts don't pile up forever.
+func (l *Limiter) prune(ctx context.Context, now time.Time) {
+	l.mu.Lock()
+	if now.Sub(l.lastPruned) < pruneInterval {
+		l.mu.Unlock()
+		return
+	}
+	l.lastPruned = now
+	l.mu.Unlock()
+
+	// Failing to prune only costs space, so the error isn't worth failing
+	// the login over.
+	_ = l.store.Prune(ctx, now.Add(-l.policy.Window))
+}
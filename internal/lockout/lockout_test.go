@@ -0,0 +1,267 @@
+package lockout
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+var testPolicy = Policy{
+	FreeAttempts:     3,
+	BaseDelay:        time.Second,
+	MaxDelay:         time.Minute,
+	LockoutThreshold: 10,
+	LockoutDuration:  time.Minute * 15,
+	Window:           time.Hour,
+}
+
+func TestPolicyDelay(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 0},
+		{1, 0},
+		{3, 0},
+		{4, time.Second},
+		{5, time.Second * 2},
+		{6, time.Second * 4},
+		{7, time.Second * 8},
+		{8, time.Second * 16},
+		{9, time.Second * 32},
+		{10, time.Minute * 15},
+		{50, time.Minute * 15},
+	}
+
+	for _, tt := range tests {
+		if got := testPolicy.Delay(tt.failures); got != tt.want {
+			t.Errorf("Delay(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestPolicyDelayCapped(t *testing.T) {
+	p := testPolicy
+	p.MaxDelay = time.Second * 5
+	p.LockoutThreshold = 1000
+
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{6, time.Second * 4},
+		{7, time.Second * 5},
+		{100, time.Second * 5},
+		{999, time.Second * 5},
+		{1000, p.LockoutDuration},
+	}
+
+	for _, tt := range tests {
+		if got := p.Delay(tt.failures); got != tt.want {
+			t.Errorf("Delay(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestReserve(t *testing.T) {
+	tests := []struct {
+		name string
+		// before is the attempts already stored, last failing ago.
+		before     Attempts
+		ago        time.Duration
+		wantWait   bool
+		wantCount  int
+		wantLocked bool
+	}{
+		{name: "first attempt", wantCount: 1},
+		{name: "free attempt", before: Attempts{Failures: 3}, wantCount: 4},
+		{name: "within delay", before: Attempts{Failures: 4}, ago: time.Millisecond * 500, wantWait: true},
+		{name: "after delay", before: Attempts{Failures: 4}, ago: time.Second * 2, wantCount: 5},
+		{name: "reaching lockout", before: Attempts{Failures: 9}, ago: time.Minute, wantCount: 10, wantLocked: true},
+		{name: "locked out", before: Attempts{Failures: 10}, ago: time.Minute * 10, wantWait: true},
+		{name: "lockout over", before: Attempts{Failures: 10}, ago: time.Minute * 16, wantCount: 11, wantLocked: true},
+		{name: "outside window", before: Attempts{Failures: 9}, ago: time.Hour * 2, wantCount: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := NewMemoryStore()
+			if tt.before.Failures > 0 {
+				tt.before.LastFailure = time.Now().Add(-tt.ago)
+				store.Update(ctx, "k", func(Attempts) Attempts { return tt.before })
+			}
+			l := NewLimiter(store, testPolicy)
+
+			r, wait, err := l.Reserve(ctx, "k")
+			if err != nil {
+				t.Fatalf("Reserve() error = %v", err)
+			}
+			if tt.wantWait {
+				if r != nil || wait <= 0 {
+					t.Fatalf("Reserve() = %v, %v, want to wait", r, wait)
+				}
+				after, _ := store.Update(ctx, "k", func(a Attempts) Attempts { return a })
+				if after.Failures != tt.before.Failures {
+					t.Errorf("failures after waiting = %d, want %d", after.Failures, tt.before.Failures)
+				}
+				return
+			}
+			if r == nil || wait != 0 {
+				t.Fatalf("Reserve() = %v, %v, want a reservation", r, wait)
+			}
+			if r.failures != tt.wantCount {
+				t.Errorf("failures = %d, want %d", r.failures, tt.wantCount)
+			}
+			if r.LockedOut() != tt.wantLocked {
+				t.Errorf("LockedOut() = %v, want %v", r.LockedOut(), tt.wantLocked)
+			}
+		})
+	}
+}
+
+func TestReserveWaitsOutTheDelay(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	store.Update(ctx, "k", func(Attempts) Attempts {
+		return Attempts{Failures: 5, LastFailure: time.Now().Add(-time.Second)}
+	})
+	l := NewLimiter(store, testPolicy)
+
+	_, wait, err := l.Reserve(ctx, "k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Five failures mean a two second delay, one of which has passed.
+	if wait <= 0 || wait > time.Second {
+		t.Errorf("wait = %v, want up to a second", wait)
+	}
+}
+
+func TestReleaseGivesBackAttempt(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	l := NewLimiter(store, testPolicy)
+
+	for range 10 {
+		r, wait, err := l.Reserve(ctx, "k")
+		if err != nil || r == nil {
+			t.Fatalf("Reserve() = %v, %v, %v", r, wait, err)
+		}
+		if err := r.Release(ctx); err != nil {
+			t.Fatalf("Release() error = %v", err)
+		}
+	}
+
+	after, _ := store.Update(ctx, "k", func(a Attempts) Attempts { return a })
+	if after.Failures != 0 {
+		t.Errorf("failures after released attempts = %d, want 0", after.Failures)
+	}
+}
+
+func TestReleaseKeepsLastFailure(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	lastFailure := time.Now().Add(-time.Minute * 59).Truncate(time.Microsecond)
+	store.Update(ctx, "k", func(Attempts) Attempts {
+		return Attempts{Failures: 2, LastFailure: lastFailure}
+	})
+	l := NewLimiter(store, testPolicy)
+
+	r, _, err := l.Reserve(ctx, "k")
+	if err != nil || r == nil {
+		t.Fatalf("Reserve() = %v, %v", r, err)
+	}
+	if err := r.Release(ctx); err != nil {
+		t.Fatalf("Release() error = %v", err)
+	}
+
+	// A success mustn't keep the old failures from leaving the window.
+	after, _ := store.Update(ctx, "k", func(a Attempts) Attempts { return a })
+	if after.Failures != 2 || !after.LastFailure.Equal(lastFailure) {
+		t.Errorf("attempts after release = %+v, want 2 failures at %v", after, lastFailure)
+	}
+}
+
+func TestReleaseKeepsLaterFailure(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	l := NewLimiter(store, testPolicy)
+
+	succeeded, _, err := l.Reserve(ctx, "k")
+	if err != nil || succeeded == nil {
+		t.Fatalf("Reserve() = %v, %v", succeeded, err)
+	}
+	time.Sleep(time.Millisecond)
+	if failed, _, err := l.Reserve(ctx, "k"); err != nil || failed == nil {
+		t.Fatalf("Reserve() = %v, %v", failed, err)
+	}
+	if err := succeeded.Release(ctx); err != nil {
+		t.Fatalf("Release() error = %v", err)
+	}
+
+	after, _ := store.Update(ctx, "k", func(a Attempts) Attempts { return a })
+	if after.Failures != 1 || !after.LastFailure.After(succeeded.reservedAt) {
+		t.Errorf("attempts after release = %+v, want the later failure", after)
+	}
+}
+
+func TestReserveConcurrent(t *testing.T) {
+	// Every attempt after the free ones has to wait an hour, so of many
+	// parallel attempts exactly the free ones and the first delayed one may
+	// get a reservation.
+	p := testPolicy
+	p.BaseDelay = time.Hour
+	p.MaxDelay = time.Hour
+	l := NewLimiter(NewMemoryStore(), p)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	reserved := 0
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r, _, err := l.Reserve(context.Background(), "k")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if r != nil {
+				mu.Lock()
+				reserved++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if want := p.FreeAttempts + 1; reserved != want {
+		t.Errorf("%d attempts reserved, want %d", reserved, want)
+	}
+}
+
+func TestReset(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	store.Update(ctx, "k", func(Attempts) Attempts {
+		return Attempts{Failures: testPolicy.LockoutThreshold, LastFailure: time.Now()}
+	})
+	l := NewLimiter(store, testPolicy)
+
+	if r, wait, _ := l.Reserve(ctx, "k"); r != nil || wait <= testPolicy.MaxDelay {
+		t.Fatalf("Reserve() = %v, %v, want to wait out the lockout", r, wait)
+	}
+
+	if err := l.Reset(ctx, "k"); err != nil {
+		t.Fatalf("Reset() error = %v", err)
+	}
+	r, wait, err := l.Reserve(ctx, "k")
+	if err != nil || r == nil {
+		t.Fatalf("Reserve() after Reset = %v, %v, %v", r, wait, err)
+	}
+	if r.failures != 1 {
+		t.Errorf("failures after Reset = %d, want 1", r.failures)
+	}
+}
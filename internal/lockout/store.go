@@ -0,0 +1,111 @@
+package lockout
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+
+	"github.com/potom-dev/backend/internal/database"
+)
+
+type MemoryStore struct {
+	mu       sync.Mutex
+	attempts map[string]Attempts
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{attempts: map[string]Attempts{}}
+}
+
+func (s *MemoryStore) Update(ctx context.Context, key string, fn func(Attempts) Attempts) (Attempts, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attempts := fn(s.attempts[key])
+	if attempts.Failures == 0 {
+		delete(s.attempts, key)
+	} else {
+		s.attempts[key] = attempts
+	}
+
+	return attempts, nil
+}
+
+func (s *MemoryStore) Reset(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.attempts, key)
+	return nil
+}
+
+func (s *MemoryStore) Prune(ctx context.Context, before time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, attempts := range s.attempts {
+		if attempts.LastFailure.Before(before) {
+			delete(s.attempts, key)
+		}
+	}
+	return nil
+}
+
+// PostgresStore keeps attempts in the login_attempts table.
+type PostgresStore struct {
+	db *sql.DB
+}
+
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+func (s *PostgresStore) Update(ctx context.Context, key string, fn func(Attempts) Attempts) (Attempts, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Attempts{}, err
+	}
+	defer tx.Rollback()
+
+	q := database.New(tx)
+
+	// The row, created empty if there is none, stays locked until the
+	// transaction ends, which makes concurrent updates of key take turns.
+	attempt, err := q.LockLoginAttempt(ctx, database.LockLoginAttemptParams{
+		Key:           key,
+		LastFailureAt: time.Now(),
+	})
+	if err != nil {
+		return Attempts{}, err
+	}
+
+	attempts := Attempts{}
+	if attempt.Failures > 0 {
+		attempts = Attempts{Failures: int(attempt.Failures), LastFailure: attempt.LastFailureAt}
+	}
+	attempts = fn(attempts)
+
+	if attempts.Failures == 0 {
+		err = q.DeleteLoginAttempt(ctx, key)
+	} else {
+		err = q.UpdateLoginAttempt(ctx, database.UpdateLoginAttemptParams{
+			Key:           key,
+			Failures:      int32(attempts.Failures),
+			LastFailureAt: attempts.LastFailure,
+		})
+	}
+	if err != nil {
+		return Attempts{}, err
+	}
+
+	return attempts, tx.Commit()
+}
+
+func (s *PostgresStore) Reset(ctx context.Context, key string) error {
+	return database.New(s.db).DeleteLoginAttempt(ctx, key)
+}
+
+func (s *PostgresStore) Prune(ctx context.Context, before time.Time) error {
+	return database.New(s.db).DeleteLoginAttemptsBefore(ctx, before)
+}
@@ -0,0 +1,117 @@
+package mailer
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// SMTPMailer sends messages through an SMTP relay.
+type SMTPMailer struct {
+	host string
+	addr string
+	auth smtp.Auth
+	from string
+}
+
+// NewSMTPMailer creates a mailer for the relay at host:port. Authentication
+// is only used when username is set.
+func NewSMTPMailer(host, port, username, password, from string) *SMTPMailer {
+	m := &SMTPMailer{
+		host: host,
+		addr: net.JoinHostPort(host, port),
+		from: from,
+	}
+	if username != "" {
+		m.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return m
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if strings.ContainsAny(msg.To, "\r\n") || strings.ContainsAny(msg.Subject, "\r\n") {
+		return fmt.Errorf("invalid header value in message to %q", msg.To)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&b, "From: %s\r\n", m.from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", msg.Subject)
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+
+	return m.send(ctx, msg.To, []byte(b.String()))
+}
+
+// send does what smtp.SendMail does, but gives up when ctx is done so that a
+// stuck relay can't hold up shutdown.
+func (m *SMTPMailer) send(ctx context.Context, to string, body []byte) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", m.addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return err
+		}
+	}
+	// Closing the connection unblocks whatever exchange is in progress.
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	c, err := smtp.NewClient(conn, m.host)
+	if err != nil {
+		return withContextErr(ctx, err)
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: m.host}); err != nil {
+			return withContextErr(ctx, err)
+		}
+	}
+	if m.auth != nil {
+		if err := c.Auth(m.auth); err != nil {
+			return withContextErr(ctx, err)
+		}
+	}
+	if err := c.Mail(m.from); err != nil {
+		return withContextErr(ctx, err)
+	}
+	if err := c.Rcpt(to); err != nil {
+		return withContextErr(ctx, err)
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return withContextErr(ctx, err)
+	}
+	if _, err := w.Write(body); err != nil {
+		return withContextErr(ctx, err)
+	}
+	if err := w.Close(); err != nil {
+		return withContextErr(ctx, err)
+	}
+
+	return withContextErr(ctx, c.Quit())
+}
+
+// withContextErr reports ctx's error instead of the network error it caused.
+func withContextErr(ctx context.Context, err error) error {
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
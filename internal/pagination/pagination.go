@@ -0,0 +1,187 @@
+// Package pagination pages through lists with keyset cursors. A cursor holds
+// the sort key of the last item on a page, so the next page starts right
+// after it however many rows were added or removed in between.
+package pagination
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DefaultLimit = 50
+	MaxLimit     = 200
+)
+
+var (
+	ErrInvalidLimit  = errors.New("invalid limit")
+	ErrInvalidSort   = errors.New("invalid sort")
+	ErrInvalidCursor = errors.New("invalid cursor")
+	ErrInvalidFilter = errors.New("invalid filter")
+)
+
+// Cursor is the position after the last item of a page. Which of Time and
+// Value is set depends on the sort it was made for.
+type Cursor struct {
+	Sort  string     `json:"s"`
+	Time  *time.Time `json:"t,omitempty"`
+	Value string     `json:"v,omitempty"`
+	ID    uuid.UUID  `json:"i"`
+}
+
+// Encode returns the cursor as an opaque string for clients to send back.
+func (c Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string) (Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	var c Cursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// Params is a page request.
+type Params struct {
+	Limit int
+	// Sort is a field name, prefixed with "-" for descending order.
+	Sort string
+	// After is where the page starts, or nil for the first page.
+	After *Cursor
+}
+
+// Parse reads the limit, sort and cursor query parameters. sorts are the
+// accepted sort values, the first being the default.
+func Parse(query url.Values, sorts ...string) (Params, error) {
+	p := Params{Limit: DefaultLimit, Sort: sorts[0]}
+
+	if s := query.Get("limit"); s != "" {
+		limit, err := strconv.Atoi(s)
+		if err != nil || limit < 1 || limit > MaxLimit {
+			return p, ErrInvalidLimit
+		}
+		p.Limit = limit
+	}
+
+	if s := query.Get("sort"); s != "" {
+		if !slices.Contains(sorts, s) {
+			return p, ErrInvalidSort
+		}
+		p.Sort = s
+	}
+
+	if s := query.Get("cursor"); s != "" {
+		c, err := decodeCursor(s)
+		if err != nil {
+			return p, err
+		}
+		// A cursor only makes sense in the order it was made for.
+		if c.Sort != p.Sort {
+			return p, ErrInvalidCursor
+		}
+		p.After = &c
+	}
+
+	return p, nil
+}
+
+// FetchLimit is how many rows to load for the page: one more than the page
+// holds, to find out whether there is a next page.
+func (p Params) FetchLimit() int32 {
+	return int32(p.Limit + 1)
+}
+
+// AfterTime returns the cursor's time, for lists sorted by a time.
+func (p Params) AfterTime() sql.NullTime {
+	if p.After == nil || p.After.Time == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: *p.After.Time, Valid: true}
+}
+
+// AfterValue returns the cursor's value, for lists sorted by a string.
+func (p Params) AfterValue() sql.NullString {
+	if p.After == nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: p.After.Value, Valid: true}
+}
+
+// AfterID returns the cursor's ID, which breaks ties between equal sort keys.
+func (p Params) AfterID() uuid.NullUUID {
+	if p.After == nil {
+		return uuid.NullUUID{}
+	}
+	return uuid.NullUUID{UUID: p.After.ID, Valid: true}
+}
+
+// Page is the envelope list endpoints respond with.
+type Page[T any] struct {
+	Items []T `json:"items"`
+	// NextCursor fetches the next page. It is empty on the last page.
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// NewPage builds the page from rows fetched with FetchLimit. cursor returns
+// the position of a row in the requested sort, and item converts a row for
+// the response.
+func NewPage[R, T any](rows []R, p Params, cursor func(R) Cursor, item func(R) T) Page[T] {
+	page := Page[T]{Items: []T{}}
+
+	if len(rows) > p.Limit {
+		rows = rows[:p.Limit]
+		next := cursor(rows[p.Limit-1])
+		next.Sort = p.Sort
+		page.NextCursor = next.Encode()
+	}
+
+	for _, row := range rows {
+		page.Items = append(page.Items, item(row))
+	}
+
+	return page
+}
+
+// PrefixFilter returns a LIKE pattern for the values starting with the query
+// parameter name, or null when it isn't set.
+func PrefixFilter(query url.Values, name string) sql.NullString {
+	s := query.Get(name)
+	if s == "" {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: likeEscaper.Replace(s), Valid: true}
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// TimeFilter parses the RFC 3339 time in the query parameter name, or
+// returns null when it isn't set.
+func TimeFilter(query url.Values, name string) (sql.NullTime, error) {
+	s := query.Get(name)
+	if s == "" {
+		return sql.NullTime{}, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return sql.NullTime{}, fmt.Errorf("%w: %s", ErrInvalidFilter, name)
+	}
+	return sql.NullTime{Time: t.UTC(), Valid: true}, nil
+}
@@ -0,0 +1,299 @@
+package pagination
+
+import (
+	"cmp"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var sorts = []string{"created_at", "-created_at", "name", "-name"}
+
+type row struct {
+	id        uuid.UUID
+	name      string
+	createdAt time.Time
+}
+
+func rowCursor(r row) Cursor {
+	return Cursor{Time: &r.createdAt, Value: r.name, ID: r.id}
+}
+
+func rowName(r row) string {
+	return r.name
+}
+
+func rowID(r row) uuid.UUID {
+	return r.id
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	createdAt := time.Date(2025, 3, 14, 15, 9, 26, 535897000, time.UTC)
+
+	tests := []struct {
+		name   string
+		sort   string
+		cursor Cursor
+	}{
+		{"time", "created_at", Cursor{Time: &createdAt, ID: uuid.New()}},
+		{"time descending", "-created_at", Cursor{Time: &createdAt, ID: uuid.New()}},
+		{"value", "name", Cursor{Value: "Émile's group", ID: uuid.New()}},
+		{"empty value", "-name", Cursor{ID: uuid.New()}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cursor.Sort = tt.sort
+			query := url.Values{"sort": {tt.sort}, "cursor": {tt.cursor.Encode()}}
+
+			p, err := Parse(query, sorts...)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if p.After == nil {
+				t.Fatal("Parse() dropped the cursor")
+			}
+			if got := p.AfterID(); !got.Valid || got.UUID != tt.cursor.ID {
+				t.Errorf("AfterID() = %v, want %s", got, tt.cursor.ID)
+			}
+			if got := p.AfterValue(); got.String != tt.cursor.Value {
+				t.Errorf("AfterValue() = %q, want %q", got.String, tt.cursor.Value)
+			}
+			gotTime := p.AfterTime()
+			if tt.cursor.Time == nil {
+				if gotTime.Valid {
+					t.Errorf("AfterTime() = %v, want null", gotTime.Time)
+				}
+			} else if !gotTime.Valid || !gotTime.Time.Equal(*tt.cursor.Time) {
+				t.Errorf("AfterTime() = %v, want %v", gotTime, *tt.cursor.Time)
+			}
+		})
+	}
+}
+
+func TestCursorTampering(t *testing.T) {
+	valid := Cursor{Sort: "created_at", Value: "x", ID: uuid.New()}.Encode()
+	encode := func(s string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(s))
+	}
+
+	tests := []struct {
+		name   string
+		sort   string
+		cursor string
+	}{
+		{"not base64", "created_at", "not a cursor!"},
+		{"padded base64", "created_at", valid + "=="},
+		{"truncated", "created_at", valid[:len(valid)-4]},
+		{"not JSON", "created_at", encode("created_at")},
+		{"wrong field type", "created_at", encode(`{"s":"created_at","t":"yesterday","i":"` + uuid.NewString() + `"}`)},
+		{"bad ID", "created_at", encode(`{"s":"created_at","i":"1 OR 1=1"}`)},
+		{"made for another sort", "-created_at", valid},
+		{"sort changed", "created_at", encode(`{"s":"name","i":"` + uuid.NewString() + `"}`)},
+		{"no sort", "created_at", encode(`{"i":"` + uuid.NewString() + `"}`)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(url.Values{"sort": {tt.sort}, "cursor": {tt.cursor}}, sorts...)
+			if !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("Parse() error = %v, want ErrInvalidCursor", err)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string
+		wantLimit int
+		wantSort  string
+		wantErr   error
+	}{
+		{name: "defaults", query: "", wantLimit: DefaultLimit, wantSort: "created_at"},
+		{name: "limit", query: "limit=10", wantLimit: 10, wantSort: "created_at"},
+		{name: "max limit", query: "limit=200", wantLimit: MaxLimit, wantSort: "created_at"},
+		{name: "sort", query: "sort=-name", wantLimit: DefaultLimit, wantSort: "-name"},
+		{name: "zero limit", query: "limit=0", wantErr: ErrInvalidLimit},
+		{name: "limit too large", query: "limit=201", wantErr: ErrInvalidLimit},
+		{name: "limit not a number", query: "limit=ten", wantErr: ErrInvalidLimit},
+		{name: "unknown sort", query: "sort=password_hash", wantErr: ErrInvalidSort},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			p, err := Parse(query, sorts...)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Parse() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if p.Limit != tt.wantLimit || p.Sort != tt.wantSort || p.After != nil {
+				t.Errorf("Parse() = %+v, want limit %d and sort %s", p, tt.wantLimit, tt.wantSort)
+			}
+		})
+	}
+}
+
+func TestNewPage(t *testing.T) {
+	now := time.Now().UTC()
+	rows := []row{
+		{uuid.New(), "a", now},
+		{uuid.New(), "b", now.Add(time.Second)},
+		{uuid.New(), "c", now.Add(2 * time.Second)},
+	}
+
+	tests := []struct {
+		name     string
+		limit    int
+		rows     int
+		wantLen  int
+		wantNext bool
+	}{
+		{"more rows than the page", 2, 3, 2, true},
+		{"exactly a page", 3, 3, 3, false},
+		{"short page", 5, 3, 3, false},
+		{"empty", 2, 0, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := Params{Limit: tt.limit, Sort: "name"}
+			page := NewPage(rows[:tt.rows], p, rowCursor, rowName)
+
+			if len(page.Items) != tt.wantLen {
+				t.Errorf("len(Items) = %d, want %d", len(page.Items), tt.wantLen)
+			}
+			if page.Items == nil {
+				t.Error("Items is nil, want an empty list")
+			}
+			if (page.NextCursor != "") != tt.wantNext {
+				t.Fatalf("NextCursor = %q, want one: %v", page.NextCursor, tt.wantNext)
+			}
+			if !tt.wantNext {
+				return
+			}
+
+			// The next page starts after the last item of this one.
+			next, err := Parse(url.Values{"sort": {"name"}, "cursor": {page.NextCursor}}, sorts...)
+			if err != nil {
+				t.Fatalf("Parse(NextCursor) error = %v", err)
+			}
+			last := rows[tt.limit-1]
+			if next.AfterID().UUID != last.id || next.AfterValue().String != last.name {
+				t.Errorf("next page starts after %+v, want %s", next.After, last.name)
+			}
+		})
+	}
+}
+
+func TestNewPageDuplicateValues(t *testing.T) {
+	// Rows sorted by name and then ID, as ListGroupsForUserByName returns
+	// them. Paging through them with the (name, id) keyset has to return every
+	// row once, even where a page ends in the middle of a run of equal names.
+	var rows []row
+	for _, name := range []string{"a", "a", "a", "b", "b", "c"} {
+		rows = append(rows, row{uuid.New(), name, time.Now()})
+	}
+	slices.SortFunc(rows, func(a, b row) int {
+		return cmp.Or(strings.Compare(a.name, b.name), strings.Compare(a.id.String(), b.id.String()))
+	})
+
+	var seen []uuid.UUID
+	query := url.Values{"sort": {"name"}, "limit": {"2"}}
+	for range len(rows) {
+		p, err := Parse(query, sorts...)
+		if err != nil {
+			t.Fatalf("Parse() error = %v", err)
+		}
+
+		var after []row
+		for _, r := range rows {
+			if p.After == nil || r.name > p.AfterValue().String ||
+				(r.name == p.AfterValue().String && r.id.String() > p.AfterID().UUID.String()) {
+				after = append(after, r)
+			}
+		}
+		page := NewPage(after[:min(len(after), int(p.FetchLimit()))], p, rowCursor, rowID)
+		for _, id := range page.Items {
+			if len(seen) == len(rows) || id != rows[len(seen)].id {
+				t.Fatalf("row %d is %s, want %v", len(seen), id, rows)
+			}
+			seen = append(seen, id)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		query.Set("cursor", page.NextCursor)
+	}
+
+	if len(seen) != len(rows) {
+		t.Errorf("paged through %d rows, want %d", len(seen), len(rows))
+	}
+}
+
+func TestPrefixFilter(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+		valid bool
+	}{
+		{"", "", false},
+		{"ann", "ann", true},
+		{"50%", `50\%`, true},
+		{"a_b", `a\_b`, true},
+		{`back\slash`, `back\\slash`, true},
+	}
+
+	for _, tt := range tests {
+		got := PrefixFilter(url.Values{"q": {tt.value}}, "q")
+		if got.Valid != tt.valid || got.String != tt.want {
+			t.Errorf("PrefixFilter(%q) = %+v, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestTimeFilter(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Time
+		valid   bool
+		wantErr bool
+	}{
+		{value: ""},
+		{value: "2025-01-02T03:04:05Z", want: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC), valid: true},
+		{value: "2025-01-02T05:04:05+02:00", want: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC), valid: true},
+		{value: "2025-01-02", wantErr: true},
+		{value: "yesterday", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := TimeFilter(url.Values{"since": {tt.value}}, "since")
+		if tt.wantErr {
+			if !errors.Is(err, ErrInvalidFilter) || !strings.Contains(err.Error(), "since") {
+				t.Errorf("TimeFilter(%q) error = %v, want ErrInvalidFilter naming the parameter", tt.value, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("TimeFilter(%q) error = %v", tt.value, err)
+			continue
+		}
+		if got.Valid != tt.valid || !got.Time.Equal(tt.want) || (got.Valid && got.Time.Location() != time.UTC) {
+			t.Errorf("TimeFilter(%q) = %+v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
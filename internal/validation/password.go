@@ -0,0 +1,66 @@
+package validation
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	MinPasswordLength = 10
+	// MaxPasswordBytes is as much of a password as bcrypt looks at. Anything
+	// longer would be silently truncated.
+	MaxPasswordBytes = 72
+)
+
+// commonPasswords are passwords long enough for the policy that still turn
+// up near the top of every leaked password list.
+var commonPasswords = map[string]bool{
+	"1234567890":   true,
+	"0987654321":   true,
+	"1111111111":   true,
+	"123456789a":   true,
+	"1q2w3e4r5t":   true,
+	"qwertyuiop":   true,
+	"asdfghjkl1":   true,
+	"password1!":   true,
+	"password12":   true,
+	"password123":  true,
+	"password1234": true,
+	"passw0rd123":  true,
+	"iloveyou12":   true,
+	"letmein123":   true,
+	"welcome123":   true,
+	"qwerty1234":   true,
+	"qwerty12345":  true,
+	"abc1234567":   true,
+	"abcdefghij":   true,
+	"changeme123":  true,
+}
+
+// Password checks a new password against the password policy: it must be
+// between MinPasswordLength characters and MaxPasswordBytes bytes long, not
+// be a well-known password or a single repeated character, and not contain
+// the local part of the account's email address, if it is known.
+func (v *Validator) Password(field, password, email string) {
+	if password == "" {
+		v.Add(field, "is required")
+		return
+	}
+	v.Check(utf8.RuneCountInString(password) >= MinPasswordLength, field,
+		fmt.Sprintf("must be at least %d characters", MinPasswordLength))
+	v.Check(len(password) <= MaxPasswordBytes, field,
+		fmt.Sprintf("must be at most %d bytes", MaxPasswordBytes))
+
+	lower := strings.ToLower(password)
+	v.Check(!commonPasswords[lower], field, "is too common")
+
+	first, _ := utf8.DecodeRuneInString(password)
+	v.Check(strings.Trim(password, string(first)) != "", field, "must not be a single repeated character")
+
+	local, _, _ := strings.Cut(strings.ToLower(email), "@")
+	// A very short local part would reject too many good passwords.
+	if len(local) >= 4 {
+		v.Check(!strings.Contains(lower, local), field, "must not contain your email address")
+	}
+}
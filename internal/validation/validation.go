@@ -0,0 +1,113 @@
+// Package validation checks request parameters and reports every invalid
+// field at once, so clients can show each problem next to its input.
+package validation
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxEmailLength is the longest email address the users table can hold.
+const MaxEmailLength = 255
+
+// FieldError says why one field of a request is invalid. Field is the JSON
+// name of the field.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Errors lists the invalid fields of a request.
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, fe := range e {
+		msgs[i] = fe.Field + " " + fe.Message
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Validatable is implemented by request parameters that have rules.
+type Validatable interface {
+	Validate(v *Validator)
+}
+
+// Validate checks params against its rules. It returns Errors if any of them
+// fail.
+func Validate(params Validatable) error {
+	var v Validator
+	params.Validate(&v)
+	if len(v.errors) == 0 {
+		return nil
+	}
+	return v.errors
+}
+
+// Validator collects the field errors of a request. Each field only keeps
+// its first error, so rules can be chained without repeating themselves.
+type Validator struct {
+	errors Errors
+}
+
+// Add records that field is invalid.
+func (v *Validator) Add(field, message string) {
+	if v.HasError(field) {
+		return
+	}
+	v.errors = append(v.errors, FieldError{Field: field, Message: message})
+}
+
+// HasError reports whether field already failed a rule.
+func (v *Validator) HasError(field string) bool {
+	return slices.ContainsFunc(v.errors, func(fe FieldError) bool {
+		return fe.Field == field
+	})
+}
+
+// Check records message for field unless ok.
+func (v *Validator) Check(ok bool, field, message string) {
+	if !ok {
+		v.Add(field, message)
+	}
+}
+
+// Required checks that value isn't blank.
+func (v *Validator) Required(field, value string) {
+	v.Check(strings.TrimSpace(value) != "", field, "is required")
+}
+
+// MaxLength checks that value is at most n characters long.
+func (v *Validator) MaxLength(field, value string, n int) {
+	v.Check(utf8.RuneCountInString(value) <= n, field, fmt.Sprintf("must be at most %d characters", n))
+}
+
+// NonNegative checks that value isn't below zero.
+func (v *Validator) NonNegative(field string, value int64) {
+	v.Check(value >= 0, field, "must not be negative")
+}
+
+// OneOf checks that value is one of allowed.
+func (v *Validator) OneOf(field, value string, allowed ...string) {
+	v.Check(slices.Contains(allowed, value), field, "must be one of "+strings.Join(allowed, ", "))
+}
+
+// Email checks that value is a bare email address, without a display name
+// or angle brackets.
+func (v *Validator) Email(field, value string) {
+	v.Required(field, value)
+	v.Check(len(value) <= MaxEmailLength, field, fmt.Sprintf("must be at most %d characters", MaxEmailLength))
+
+	addr, err := mail.ParseAddress(value)
+	v.Check(err == nil && addr.Name == "" && addr.Address == value, field, "must be a valid email address")
+}
+
+// URL checks that value is an absolute http or https URL.
+func (v *Validator) URL(field, value string) {
+	u, err := url.Parse(value)
+	v.Check(err == nil && (u.Scheme == "https" || u.Scheme == "http") && u.Host != "", field, "must be an http or https URL")
+}
@@ -0,0 +1,162 @@
+package validation
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// check runs rule on a fresh Validator and returns the message for field, or
+// "" if the field passed.
+func check(rule func(v *Validator)) string {
+	var v Validator
+	rule(&v)
+	for _, fe := range v.errors {
+		if fe.Field == "f" {
+			return fe.Message
+		}
+	}
+	return ""
+}
+
+func TestEmail(t *testing.T) {
+	tests := []struct {
+		value string
+		valid bool
+	}{
+		{"ann@example.com", true},
+		{"ann.lee+groups@mail.example.co.uk", true},
+		{"", false},
+		{"   ", false},
+		{"ann", false},
+		{"ann@", false},
+		{"@example.com", false},
+		{"Ann <ann@example.com>", false},
+		{"<ann@example.com>", false},
+		{" ann@example.com", false},
+		{"ann@example.com, bob@example.com", false},
+		{strings.Repeat("a", MaxEmailLength-len("@example.com")) + "@example.com", true},
+		{strings.Repeat("a", MaxEmailLength-len("@example.com")+1) + "@example.com", false},
+	}
+
+	for _, tt := range tests {
+		msg := check(func(v *Validator) { v.Email("f", tt.value) })
+		if (msg == "") != tt.valid {
+			t.Errorf("Email(%q) = %q, want valid: %v", tt.value, msg, tt.valid)
+		}
+	}
+}
+
+func TestURL(t *testing.T) {
+	tests := []struct {
+		value string
+		valid bool
+	}{
+		{"https://example.com/avatar.png", true},
+		{"http://example.com", true},
+		{"", false},
+		{"example.com/avatar.png", false},
+		{"/avatar.png", false},
+		{"javascript:alert(1)", false},
+		{"ftp://example.com/avatar.png", false},
+		{"https://", false},
+		{"https://exa mple.com", false},
+	}
+
+	for _, tt := range tests {
+		msg := check(func(v *Validator) { v.URL("f", tt.value) })
+		if (msg == "") != tt.valid {
+			t.Errorf("URL(%q) = %q, want valid: %v", tt.value, msg, tt.valid)
+		}
+	}
+}
+
+func TestOneOf(t *testing.T) {
+	tests := []struct {
+		value string
+		valid bool
+	}{
+		{"owner", true},
+		{"member", true},
+		{"", false},
+		{"Owner", false},
+		{"admin", false},
+	}
+
+	for _, tt := range tests {
+		msg := check(func(v *Validator) { v.OneOf("f", tt.value, "owner", "member") })
+		if (msg == "") != tt.valid {
+			t.Errorf("OneOf(%q) = %q, want valid: %v", tt.value, msg, tt.valid)
+		}
+		if msg != "" && msg != "must be one of owner, member" {
+			t.Errorf("OneOf(%q) message = %q, want it to list the allowed values", tt.value, msg)
+		}
+	}
+}
+
+func TestPassword(t *testing.T) {
+	tests := []struct {
+		name     string
+		password string
+		email    string
+		want     string
+	}{
+		{name: "valid", password: "correct horse battery", email: "ann@example.com"},
+		{name: "empty", password: "", want: "is required"},
+		{name: "too short", password: "short pw1", want: "must be at least 10 characters"},
+		{name: "exactly the minimum", password: "tenletters"},
+		// Ten runes but twenty bytes, so counting bytes would let it through
+		// and counting runes must not reject it.
+		{name: "multibyte at the minimum", password: "ééééééééé1"},
+		{name: "multibyte too short", password: "éééééééé1", want: "must be at least 10 characters"},
+		{name: "at the bcrypt limit", password: strings.Repeat("ab", MaxPasswordBytes/2)},
+		{name: "over the bcrypt limit", password: strings.Repeat("ab", MaxPasswordBytes/2) + "c", want: "must be at most 72 bytes"},
+		{name: "multibyte over the bcrypt limit", password: strings.Repeat("é", 37), want: "must be at most 72 bytes"},
+		{name: "common", password: "password123", want: "is too common"},
+		{name: "common in another case", password: "QwertyUIOP", want: "is too common"},
+		{name: "repeated character", password: "aaaaaaaaaaaa", want: "must not be a single repeated character"},
+		{name: "repeated multibyte character", password: "éééééééééé", want: "must not be a single repeated character"},
+		{name: "contains the email local part", password: "my-annlee-pass", email: "annlee@example.com", want: "must not contain your email address"},
+		{name: "contains the local part in another case", password: "my-ANNLEE-pass", email: "AnnLee@example.com", want: "must not contain your email address"},
+		{name: "short local part is ignored", password: "ann's long password", email: "ann@example.com"},
+		{name: "no email", password: "annlee's password", email: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := check(func(v *Validator) { v.Password("f", tt.password, tt.email) }); got != tt.want {
+				t.Errorf("Password(%q, %q) = %q, want %q", tt.password, tt.email, got, tt.want)
+			}
+		})
+	}
+}
+
+type testParams struct {
+	Name  string
+	Email string
+}
+
+func (p testParams) Validate(v *Validator) {
+	v.Required("name", p.Name)
+	v.MaxLength("name", p.Name, 3)
+	v.Email("email", p.Email)
+}
+
+func TestValidate(t *testing.T) {
+	if err := Validate(testParams{Name: "ann", Email: "ann@example.com"}); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	// Every invalid field is reported, each only with its first error.
+	var fields Errors
+	if err := Validate(testParams{Email: "ann"}); !errors.As(err, &fields) {
+		t.Fatalf("Validate() error = %v, want Errors", err)
+	}
+	want := Errors{
+		{Field: "name", Message: "is required"},
+		{Field: "email", Message: "must be a valid email address"},
+	}
+	if len(fields) != len(want) || fields[0] != want[0] || fields[1] != want[1] {
+		t.Errorf("Validate() = %v, want %v", fields, want)
+	}
+}
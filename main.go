@@ -1,15 +1,31 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"embed"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/potom-dev/backend/internal/api"
 	"github.com/potom-dev/backend/internal/auth"
+	"github.com/potom-dev/backend/internal/config"
 	"github.com/potom-dev/backend/internal/database"
-	"github.com/potom-dev/backend/internal/env"
+	"github.com/potom-dev/backend/internal/denylist"
+	"github.com/potom-dev/backend/internal/lockout"
+	"github.com/potom-dev/backend/internal/mailer"
 
 	// Import pq driver for its side effects only
 	_ "github.com/lib/pq"
@@ -17,28 +33,252 @@ import (
 )
 
 func main() {
-	env.InitEnv()
+	conf, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatalf("Invalid configuration:\n%v", err)
+	}
+	log.Printf("Configuration:\n%s", conf)
 
-	auth.NewAuth()
+	auth.NewAuth(conf)
 
-	port := env.GetEnv("PORT")
-	jwtSecret := env.GetEnv("JWT_SECRET")
-	dbURL := env.GetEnv("DB_URL")
+	// The first SIGINT or SIGTERM starts a graceful shutdown, a second one
+	// kills the server.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	jwtKeys, err := newKeyRing(conf.Auth)
+	if err != nil {
+		log.Fatal(err)
+	}
+	reloadKeysOnHangup(jwtKeys)
+
+	db, err := openDB(ctx, conf.Database)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
 
-	db, err := sql.Open("postgres", dbURL)
+	mail, err := newMailer(conf.Mail)
 	if err != nil {
 		log.Fatal(err)
-		os.Exit(1)
 	}
 
-	dbQueries := database.New(db)
-	apiCfg := api.NewConfig(dbQueries, jwtSecret)
+	lockoutStore, err := newLockoutStore(conf.Auth.LockoutStore, db)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	tokenDenylist, err := newDenylist(conf.Auth.DenylistStore, db)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := tokenDenylist.Sync(ctx); err != nil {
+		log.Fatal(err)
+	}
+
+	version, err := schemaVersion()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	apiCfg := api.NewConfig(conf, db, jwtKeys, tokenDenylist, mail, lockoutStore, version)
+
+	if email := conf.Auth.BootstrapAdminEmail; email != "" {
+		if err := apiCfg.BootstrapAdmin(ctx, email); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// Background workers run until the server has stopped, so that requests
+	// being drained still see revocations synced from other instances.
+	workersCtx, stopWorkers := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+	workers.Add(2)
+	go func() {
+		defer workers.Done()
+		tokenDenylist.Run(workersCtx, conf.Auth.DenylistSyncInterval)
+	}()
+	go func() {
+		defer workers.Done()
+		apiCfg.RunAccountPurge(workersCtx, time.Hour)
+	}()
 
 	srv := &http.Server{
-		Addr:    ":" + port,
-		Handler: api.NewRouter(apiCfg),
+		Addr:              ":" + strconv.Itoa(conf.HTTP.Port),
+		Handler:           api.NewRouter(apiCfg),
+		ReadHeaderTimeout: conf.HTTP.ReadHeaderTimeout,
+		ReadTimeout:       conf.HTTP.ReadTimeout,
+		WriteTimeout:      conf.HTTP.WriteTimeout,
+		IdleTimeout:       conf.HTTP.IdleTimeout,
+		MaxHeaderBytes:    conf.HTTP.MaxHeaderBytes,
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		log.Printf("Starting server on port %d", conf.HTTP.Port)
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		log.Fatal(err)
+	case <-ctx.Done():
+	}
+	stop()
+
+	apiCfg.StartShutdown()
+	if conf.HTTP.ShutdownDelay > 0 {
+		log.Printf("Shutting down, failing readiness for %s first", conf.HTTP.ShutdownDelay)
+		time.Sleep(conf.HTTP.ShutdownDelay)
+	}
+
+	log.Printf("Shutting down, draining requests for up to %s", conf.HTTP.ShutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), conf.HTTP.ShutdownTimeout)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Error draining requests: %v", err)
+	}
+
+	stopWorkers()
+	workers.Wait()
+
+	if err := apiCfg.WaitForMail(shutdownCtx); err != nil {
+		log.Printf("Gave up on email still being sent: %v", err)
+	}
+
+	log.Printf("Server stopped")
+}
+
+//go:embed sql/schema/*.sql
+var migrations embed.FS
+
+// schemaVersion is the version of the newest migration the server was built
+// with, taken from the number its file name starts with.
+func schemaVersion() (int64, error) {
+	files, err := fs.Glob(migrations, "sql/schema/*.sql")
+	if err != nil {
+		return 0, err
 	}
 
-	log.Printf("Starting server on port %s", port)
-	log.Fatal(srv.ListenAndServe())
+	var latest int64
+	for _, file := range files {
+		prefix, _, _ := strings.Cut(path.Base(file), "_")
+		version, err := strconv.ParseInt(prefix, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("migration %s doesn't start with a version", file)
+		}
+		latest = max(latest, version)
+	}
+	return latest, nil
+}
+
+// openDB connects to Postgres and checks that it is reachable, retrying with
+// backoff for up to the connect timeout, since the database may still be
+// starting when the server does.
+func openDB(ctx context.Context, conf config.DatabaseConfig) (*sql.DB, error) {
+	db, err := sql.Open("postgres", string(conf.URL))
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(conf.MaxOpenConns)
+	db.SetMaxIdleConns(conf.MaxIdleConns)
+	db.SetConnMaxLifetime(conf.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(conf.ConnMaxIdleTime)
+
+	ctx, cancel := context.WithTimeout(ctx, conf.ConnectTimeout)
+	defer cancel()
+
+	backoff := 500 * time.Millisecond
+	for {
+		err = db.PingContext(ctx)
+		if err == nil {
+			return db, nil
+		}
+		log.Printf("Database isn't reachable, retrying in %s: %v", backoff, err)
+
+		select {
+		case <-ctx.Done():
+			db.Close()
+			return nil, fmt.Errorf("couldn't connect to database within %s: %w", conf.ConnectTimeout, err)
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, 10*time.Second)
+	}
+}
+
+// newKeyRing signs access tokens with the keys in the JWT keys directory.
+// Without one, tokens are signed with the HS256 JWT secret.
+func newKeyRing(conf config.AuthConfig) (*auth.KeyRing, error) {
+	if conf.JWTKeysDir == "" {
+		return auth.NewHMACKeyRing(string(conf.JWTSecret)), nil
+	}
+	return auth.LoadKeyRing(conf.JWTKeysDir, string(conf.JWTSecret))
+}
+
+// reloadKeysOnHangup rereads the signing keys on SIGHUP, for rotating keys
+// without a restart.
+func reloadKeysOnHangup(keys *auth.KeyRing) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+
+	go func() {
+		for range hangup {
+			if err := keys.Reload(); err != nil {
+				log.Printf("Error reloading signing keys: %v", err)
+				continue
+			}
+			log.Printf("Reloaded signing keys, signing with %q", keys.ActiveKeyID())
+		}
+	}()
+}
+
+// newMailer picks the mail transport: "smtp", "file" (append to the mail
+// file) or "stdout", the default for local development.
+func newMailer(conf config.MailConfig) (mailer.Mailer, error) {
+	switch conf.Mailer {
+	case "smtp":
+		return mailer.NewSMTPMailer(
+			conf.SMTP.Host,
+			strconv.Itoa(conf.SMTP.Port),
+			conf.SMTP.Username,
+			string(conf.SMTP.Password),
+			conf.From,
+		), nil
+	case "file":
+		return mailer.NewFileMailer(conf.File, conf.From)
+	case "stdout":
+		return mailer.NewWriterMailer(os.Stdout, conf.From), nil
+	default:
+		return nil, fmt.Errorf("unknown MAILER %q", conf.Mailer)
+	}
+}
+
+// newLockoutStore picks where failed logins are tracked: "memory", the
+// default, or "postgres" when running more than one instance.
+func newLockoutStore(kind string, db *sql.DB) (lockout.Store, error) {
+	switch kind {
+	case "memory":
+		return lockout.NewMemoryStore(), nil
+	case "postgres":
+		return lockout.NewPostgresStore(db), nil
+	default:
+		return nil, fmt.Errorf("unknown LOCKOUT_STORE %q", kind)
+	}
+}
+
+// newDenylist picks where revoked access tokens are kept: "memory", the
+// default, or "postgres" so that revocations reach every instance.
+func newDenylist(kind string, db *sql.DB) (*denylist.Denylist, error) {
+	switch kind {
+	case "memory":
+		return denylist.New(nil), nil
+	case "postgres":
+		return denylist.New(denylist.NewPostgresStore(database.New(db))), nil
+	default:
+		return nil, fmt.Errorf("unknown DENYLIST_STORE %q", kind)
+	}
 }
@@ -0,0 +1,34 @@
+-- name: CreateApiKey :one
+INSERT INTO api_keys (user_id, name, prefix, key_hash, scopes, expires_at)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING *;
+
+-- name: GetApiKeyByHash :one
+SELECT api_keys.*, users.email_verified_at, users.disabled_at, users.deleted_at, users.password_reset_required FROM api_keys
+JOIN users ON users.id = api_keys.user_id
+WHERE api_keys.key_hash = $1;
+
+-- name: GetApiKeys :many
+SELECT * FROM api_keys
+WHERE user_id = $1
+ORDER BY created_at ASC;
+
+-- name: ListApiKeys :many
+SELECT * FROM api_keys
+WHERE user_id = sqlc.arg('user_id')
+  AND (sqlc.narg('after_created_at')::timestamp IS NULL OR (created_at, id) > (sqlc.narg('after_created_at'), sqlc.narg('after_id')::uuid))
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg('limit');
+
+-- name: UpdateApiKeyLastUsed :exec
+UPDATE api_keys
+SET last_used_at = $2
+WHERE id = $1;
+
+-- name: DeleteApiKey :execrows
+DELETE FROM api_keys
+WHERE id = $1 AND user_id = $2;
+
+-- name: DeleteApiKeysForUser :exec
+DELETE FROM api_keys
+WHERE user_id = $1;
@@ -0,0 +1,8 @@
+-- name: CreateAuditEvent :exec
+INSERT INTO audit_events (user_id, event_type, ip_address, detail)
+VALUES ($1, $2, $3, $4);
+
+-- name: GetAuditEventsForUser :many
+SELECT * FROM audit_events
+WHERE user_id = $1
+ORDER BY created_at ASC;
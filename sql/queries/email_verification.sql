@@ -0,0 +1,23 @@
+-- name: CreateEmailVerificationToken :exec
+INSERT INTO email_verification_tokens (token_hash, user_id, email, expires_at)
+VALUES ($1, $2, $3, $4);
+
+-- name: UseEmailVerificationToken :one
+UPDATE email_verification_tokens
+SET used_at = CURRENT_TIMESTAMP
+WHERE token_hash = $1 AND used_at IS NULL
+RETURNING *;
+
+-- name: MarkUserEmailVerified :execrows
+UPDATE users
+SET email_verified_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1 AND email = $2;
+
+-- name: ChangeUserEmail :exec
+UPDATE users
+SET email = $2, email_verified_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1;
+
+-- name: DeleteEmailVerificationTokensForUser :exec
+DELETE FROM email_verification_tokens
+WHERE user_id = $1;
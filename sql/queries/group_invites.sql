@@ -0,0 +1,44 @@
+-- name: CreateGroupInvite :one
+INSERT INTO group_invites (group_id, created_by, token_hash, email, role, max_uses, expires_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+RETURNING *;
+
+-- name: GetGroupInviteByTokenHash :one
+SELECT * FROM group_invites
+WHERE token_hash = $1;
+
+-- name: LockGroupInviteByTokenHash :one
+SELECT * FROM group_invites
+WHERE token_hash = $1
+FOR UPDATE;
+
+-- name: ListPendingGroupInvites :many
+SELECT * FROM group_invites
+WHERE group_id = sqlc.arg('group_id')
+  AND revoked_at IS NULL
+  AND declined_at IS NULL
+  AND expires_at > CURRENT_TIMESTAMP
+  AND (max_uses IS NULL OR uses < max_uses)
+  AND (sqlc.narg('after_created_at')::timestamp IS NULL OR (created_at, id) > (sqlc.narg('after_created_at'), sqlc.narg('after_id')::uuid))
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg('limit');
+
+-- name: IncrementGroupInviteUses :exec
+UPDATE group_invites
+SET uses = uses + 1, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1;
+
+-- name: DeclineGroupInvite :execrows
+UPDATE group_invites
+SET declined_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1 AND declined_at IS NULL AND revoked_at IS NULL;
+
+-- name: RevokeGroupInvite :execrows
+UPDATE group_invites
+SET revoked_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1 AND group_id = $2 AND revoked_at IS NULL;
+
+-- name: GetGroupInvitesCreatedBy :many
+SELECT * FROM group_invites
+WHERE created_by = $1
+ORDER BY created_at ASC;
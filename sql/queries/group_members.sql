@@ -0,0 +1,43 @@
+-- name: AddGroupMember :one
+INSERT INTO group_members (group_id, user_id, role)
+VALUES ($1, $2, $3)
+RETURNING *;
+
+-- name: GetGroupMember :one
+SELECT * FROM group_members
+WHERE group_id = $1 AND user_id = $2;
+
+-- name: ListGroupMembers :many
+SELECT group_members.*, users.email FROM group_members
+JOIN users ON users.id = group_members.user_id
+WHERE group_members.group_id = sqlc.arg('group_id')
+  AND (sqlc.narg('after_created_at')::timestamp IS NULL OR (group_members.created_at, group_members.user_id) > (sqlc.narg('after_created_at'), sqlc.narg('after_id')::uuid))
+ORDER BY group_members.created_at ASC, group_members.user_id ASC
+LIMIT sqlc.arg('limit');
+
+-- name: UpdateGroupMemberRole :one
+UPDATE group_members
+SET role = $3, updated_at = CURRENT_TIMESTAMP
+WHERE group_id = $1 AND user_id = $2
+RETURNING *;
+
+-- name: RemoveGroupMember :exec
+DELETE FROM group_members
+WHERE group_id = $1 AND user_id = $2;
+
+-- name: LockGroupOwners :many
+SELECT user_id FROM group_members
+WHERE group_id = $1 AND role = 'owner'
+FOR UPDATE;
+
+-- name: GetGroupSuccessor :one
+SELECT * FROM group_members
+WHERE group_id = $1 AND user_id <> $2
+ORDER BY CASE role WHEN 'owner' THEN 0 WHEN 'admin' THEN 1 ELSE 2 END, created_at ASC
+LIMIT 1;
+
+-- name: GetGroupMembershipsForUser :many
+SELECT group_members.group_id, groups.name, group_members.role, group_members.created_at FROM group_members
+JOIN groups ON groups.id = group_members.group_id
+WHERE group_members.user_id = $1
+ORDER BY group_members.created_at ASC;
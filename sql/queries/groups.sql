@@ -8,3 +8,64 @@ VALUES (
     $2
 )
 RETURNING *;
+
+-- name: GetGroupById :one
+SELECT * FROM groups
+WHERE id = $1;
+
+-- name: ListGroupsForUserByCreatedAt :many
+SELECT groups.* FROM groups
+JOIN group_members ON group_members.group_id = groups.id
+WHERE group_members.user_id = sqlc.arg('user_id')
+  AND (sqlc.narg('name_prefix')::text IS NULL OR groups.name ILIKE sqlc.narg('name_prefix') || '%')
+  AND (sqlc.narg('after_created_at')::timestamp IS NULL OR (groups.created_at, groups.id) > (sqlc.narg('after_created_at'), sqlc.narg('after_id')::uuid))
+ORDER BY groups.created_at ASC, groups.id ASC
+LIMIT sqlc.arg('limit');
+
+-- name: ListGroupsForUserByCreatedAtDesc :many
+SELECT groups.* FROM groups
+JOIN group_members ON group_members.group_id = groups.id
+WHERE group_members.user_id = sqlc.arg('user_id')
+  AND (sqlc.narg('name_prefix')::text IS NULL OR groups.name ILIKE sqlc.narg('name_prefix') || '%')
+  AND (sqlc.narg('after_created_at')::timestamp IS NULL OR (groups.created_at, groups.id) < (sqlc.narg('after_created_at'), sqlc.narg('after_id')::uuid))
+ORDER BY groups.created_at DESC, groups.id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: ListGroupsForUserByName :many
+SELECT groups.* FROM groups
+JOIN group_members ON group_members.group_id = groups.id
+WHERE group_members.user_id = sqlc.arg('user_id')
+  AND (sqlc.narg('name_prefix')::text IS NULL OR groups.name ILIKE sqlc.narg('name_prefix') || '%')
+  AND (sqlc.narg('after_name')::text IS NULL OR (groups.name, groups.id) > (sqlc.narg('after_name'), sqlc.narg('after_id')::uuid))
+ORDER BY groups.name ASC, groups.id ASC
+LIMIT sqlc.arg('limit');
+
+-- name: ListGroupsForUserByNameDesc :many
+SELECT groups.* FROM groups
+JOIN group_members ON group_members.group_id = groups.id
+WHERE group_members.user_id = sqlc.arg('user_id')
+  AND (sqlc.narg('name_prefix')::text IS NULL OR groups.name ILIKE sqlc.narg('name_prefix') || '%')
+  AND (sqlc.narg('after_name')::text IS NULL OR (groups.name, groups.id) < (sqlc.narg('after_name'), sqlc.narg('after_id')::uuid))
+ORDER BY groups.name DESC, groups.id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: UpdateGroupName :one
+UPDATE groups
+SET name = $2, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1
+RETURNING *;
+
+-- name: DeleteGroup :exec
+DELETE FROM groups
+WHERE id = $1;
+
+-- name: GetGroupsOwnedOrAuthoredBy :many
+SELECT * FROM groups
+WHERE author_id = $1
+   OR id IN (SELECT group_id FROM group_members WHERE user_id = $1 AND role = 'owner')
+ORDER BY created_at ASC;
+
+-- name: UpdateGroupAuthor :exec
+UPDATE groups
+SET author_id = $2, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1;
@@ -0,0 +1,38 @@
+-- name: CreateUserIdentity :one
+INSERT INTO user_identities (user_id, provider, provider_user_id, email)
+VALUES ($1, $2, $3, $4)
+RETURNING *;
+
+-- name: GetUserIdentity :one
+SELECT * FROM user_identities
+WHERE provider = $1 AND provider_user_id = $2;
+
+-- name: GetUserIdentities :many
+SELECT * FROM user_identities
+WHERE user_id = $1
+ORDER BY created_at ASC;
+
+-- name: ListUserIdentities :many
+SELECT * FROM user_identities
+WHERE user_id = sqlc.arg('user_id')
+  AND (sqlc.narg('after_created_at')::timestamp IS NULL OR (created_at, id) > (sqlc.narg('after_created_at'), sqlc.narg('after_id')::uuid))
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg('limit');
+
+-- name: CountUserIdentities :one
+SELECT COUNT(*) FROM user_identities
+WHERE user_id = $1;
+
+-- name: DeleteUserIdentity :execrows
+DELETE FROM user_identities
+WHERE id = $1 AND user_id = $2;
+
+-- name: CreateOauthCode :exec
+INSERT INTO oauth_codes (code_hash, user_id, purpose, expires_at)
+VALUES ($1, $2, $3, $4);
+
+-- name: UseOauthCode :one
+UPDATE oauth_codes
+SET used_at = CURRENT_TIMESTAMP
+WHERE code_hash = $1 AND purpose = $2 AND used_at IS NULL
+RETURNING *;
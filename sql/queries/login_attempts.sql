@@ -0,0 +1,19 @@
+-- name: LockLoginAttempt :one
+INSERT INTO login_attempts (key, failures, last_failure_at)
+VALUES ($1, 0, $2)
+ON CONFLICT (key) DO UPDATE
+SET key = EXCLUDED.key
+RETURNING *;
+
+-- name: UpdateLoginAttempt :exec
+UPDATE login_attempts
+SET failures = $2, last_failure_at = $3
+WHERE key = $1;
+
+-- name: DeleteLoginAttempt :exec
+DELETE FROM login_attempts
+WHERE key = $1;
+
+-- name: DeleteLoginAttemptsBefore :exec
+DELETE FROM login_attempts
+WHERE last_failure_at < $1;
@@ -0,0 +1,57 @@
+-- name: GetUserTotp :one
+SELECT * FROM user_totp
+WHERE user_id = $1;
+
+-- name: UpsertUserTotp :one
+INSERT INTO user_totp (user_id, secret)
+VALUES ($1, $2)
+ON CONFLICT (user_id) DO UPDATE
+SET secret = EXCLUDED.secret, last_used_step = 0, updated_at = CURRENT_TIMESTAMP
+WHERE user_totp.confirmed_at IS NULL
+RETURNING *;
+
+-- name: ConfirmUserTotp :exec
+UPDATE user_totp
+SET confirmed_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+WHERE user_id = $1;
+
+-- name: UseTotpStep :execrows
+UPDATE user_totp
+SET last_used_step = $2, updated_at = CURRENT_TIMESTAMP
+WHERE user_id = $1 AND last_used_step < $2;
+
+-- name: DeleteUserTotp :exec
+DELETE FROM user_totp
+WHERE user_id = $1;
+
+-- name: CreateRecoveryCode :exec
+INSERT INTO mfa_recovery_codes (user_id, code_hash)
+VALUES ($1, $2);
+
+-- name: UseRecoveryCode :execrows
+UPDATE mfa_recovery_codes
+SET used_at = CURRENT_TIMESTAMP
+WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL;
+
+-- name: DeleteRecoveryCodes :exec
+DELETE FROM mfa_recovery_codes
+WHERE user_id = $1;
+
+-- name: CreateMfaChallenge :exec
+INSERT INTO mfa_challenges (token_hash, user_id, device_label, expires_at, expires_in_seconds)
+VALUES ($1, $2, $3, $4, $5);
+
+-- name: GetMfaChallenge :one
+SELECT * FROM mfa_challenges
+WHERE token_hash = $1;
+
+-- name: IncrementMfaChallengeAttempts :one
+UPDATE mfa_challenges
+SET attempts = attempts + 1
+WHERE token_hash = $1
+RETURNING attempts;
+
+-- name: UseMfaChallenge :execrows
+UPDATE mfa_challenges
+SET used_at = CURRENT_TIMESTAMP
+WHERE token_hash = $1 AND used_at IS NULL;
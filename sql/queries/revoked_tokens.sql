@@ -0,0 +1,12 @@
+-- name: CreateRevokedToken :exec
+INSERT INTO revoked_tokens (kind, value, revoked_at, expires_at)
+VALUES ($1, $2, $3, $4);
+
+-- name: GetRevokedTokensSince :many
+SELECT * FROM revoked_tokens
+WHERE created_at > $1 AND expires_at > $2
+ORDER BY created_at ASC, id ASC;
+
+-- name: DeleteExpiredRevokedTokens :exec
+DELETE FROM revoked_tokens
+WHERE expires_at < $1;
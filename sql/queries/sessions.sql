@@ -0,0 +1,22 @@
+-- name: ListActiveSessions :many
+SELECT * FROM refresh_tokens
+WHERE user_id = sqlc.arg('user_id') AND revoked_at IS NULL AND expires_at > CURRENT_TIMESTAMP
+  AND (sqlc.narg('after_created_at')::timestamp IS NULL OR (created_at, id) < (sqlc.narg('after_created_at'), sqlc.narg('after_id')::uuid))
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: RevokeSession :execrows
+UPDATE refresh_tokens
+SET revoked_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+WHERE family_id = $1 AND user_id = $2 AND revoked_at IS NULL;
+
+-- name: RevokeOtherSessions :many
+UPDATE refresh_tokens
+SET revoked_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+WHERE user_id = $1 AND family_id <> $2 AND revoked_at IS NULL
+RETURNING family_id;
+
+-- name: GetRefreshTokensForUser :many
+SELECT * FROM refresh_tokens
+WHERE user_id = $1
+ORDER BY created_at ASC;
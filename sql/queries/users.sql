@@ -9,21 +9,102 @@ VALUES (
 )
 RETURNING *;
 
--- name: GetUsers :many
+-- name: CreateUserWithoutPassword :one
+INSERT INTO users (email, email_verified_at)
+VALUES ($1, $2)
+RETURNING *;
+
+-- name: ListUsersByCreatedAt :many
+SELECT * FROM users
+WHERE (sqlc.narg('email_prefix')::text IS NULL OR email ILIKE sqlc.narg('email_prefix') || '%')
+  AND (sqlc.narg('created_after')::timestamp IS NULL OR created_at >= sqlc.narg('created_after'))
+  AND (sqlc.narg('created_before')::timestamp IS NULL OR created_at < sqlc.narg('created_before'))
+  AND (sqlc.narg('after_created_at')::timestamp IS NULL OR (created_at, id) > (sqlc.narg('after_created_at'), sqlc.narg('after_id')::uuid))
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg('limit');
+
+-- name: ListUsersByCreatedAtDesc :many
+SELECT * FROM users
+WHERE (sqlc.narg('email_prefix')::text IS NULL OR email ILIKE sqlc.narg('email_prefix') || '%')
+  AND (sqlc.narg('created_after')::timestamp IS NULL OR created_at >= sqlc.narg('created_after'))
+  AND (sqlc.narg('created_before')::timestamp IS NULL OR created_at < sqlc.narg('created_before'))
+  AND (sqlc.narg('after_created_at')::timestamp IS NULL OR (created_at, id) < (sqlc.narg('after_created_at'), sqlc.narg('after_id')::uuid))
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: ListUsersByEmail :many
+SELECT * FROM users
+WHERE (sqlc.narg('email_prefix')::text IS NULL OR email ILIKE sqlc.narg('email_prefix') || '%')
+  AND (sqlc.narg('created_after')::timestamp IS NULL OR created_at >= sqlc.narg('created_after'))
+  AND (sqlc.narg('created_before')::timestamp IS NULL OR created_at < sqlc.narg('created_before'))
+  AND (sqlc.narg('after_email')::text IS NULL OR email > sqlc.narg('after_email'))
+ORDER BY email ASC
+LIMIT sqlc.arg('limit');
+
+-- name: ListUsersByEmailDesc :many
 SELECT * FROM users
-ORDER BY created_at ASC;
+WHERE (sqlc.narg('email_prefix')::text IS NULL OR email ILIKE sqlc.narg('email_prefix') || '%')
+  AND (sqlc.narg('created_after')::timestamp IS NULL OR created_at >= sqlc.narg('created_after'))
+  AND (sqlc.narg('created_before')::timestamp IS NULL OR created_at < sqlc.narg('created_before'))
+  AND (sqlc.narg('after_email')::text IS NULL OR email < sqlc.narg('after_email'))
+ORDER BY email DESC
+LIMIT sqlc.arg('limit');
 
 -- name: GetUserById :one
 SELECT * FROM users
 WHERE id = $1;
 
+-- name: LockUserById :one
+SELECT * FROM users
+WHERE id = $1
+FOR UPDATE;
+
 -- name: GetUserByEmail :one
 SELECT * FROM users
 WHERE email = $1;
 
--- name: UpdateUser :exec
+-- name: UpdateUserProfile :one
+UPDATE users
+SET email = $2, email_verified_at = $3, display_name = $4, avatar_url = $5, locale = $6, timezone = $7, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1
+RETURNING *;
+
+-- name: UpdateUserPassword :exec
+UPDATE users
+SET password_hash = $2, password_reset_required = false, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1;
+
+-- name: UpdateUserRole :exec
+UPDATE users
+SET role = $2, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1;
+
+-- name: UpdateUserDisabledAt :exec
 UPDATE users
-SET email = $2, password_hash = $3
+SET disabled_at = $2, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1;
+
+-- name: RequireUserPasswordReset :exec
+UPDATE users
+SET password_reset_required = true, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1;
+
+-- name: CountAdmins :one
+SELECT COUNT(*) FROM users
+WHERE role = 'admin';
+
+-- name: UpdateUserDeletedAt :exec
+UPDATE users
+SET deleted_at = $2, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1;
+
+-- name: GetUsersDeletedBefore :many
+SELECT * FROM users
+WHERE deleted_at < $1
+ORDER BY deleted_at ASC;
+
+-- name: DeleteUser :exec
+DELETE FROM users
 WHERE id = $1;
 
 -- name: DeleteAllUsers :exec
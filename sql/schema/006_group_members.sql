@@ -0,0 +1,15 @@
+-- +goose Up
+CREATE TABLE group_members (
+  group_id uuid NOT NULL REFERENCES groups(id) ON DELETE CASCADE,
+  user_id uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  role TEXT NOT NULL CHECK (role IN ('owner', 'admin', 'member')),
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (group_id, user_id)
+);
+
+INSERT INTO group_members (group_id, user_id, role)
+SELECT id, author_id, 'owner' FROM groups;
+
+-- +goose Down
+DROP TABLE group_members;
@@ -0,0 +1,48 @@
+-- +goose Up
+ALTER TABLE refresh_tokens
+ADD COLUMN id uuid NOT NULL DEFAULT gen_random_uuid(),
+ADD COLUMN token_hash TEXT,
+ADD COLUMN user_agent TEXT,
+ADD COLUMN ip_address TEXT,
+ADD COLUMN device_label TEXT,
+ADD COLUMN last_used_at TIMESTAMP;
+
+UPDATE refresh_tokens
+SET token_hash = encode(sha256(convert_to(token, 'UTF8')), 'hex'),
+    replaced_by = encode(sha256(convert_to(replaced_by, 'UTF8')), 'hex');
+
+ALTER TABLE refresh_tokens
+DROP CONSTRAINT refresh_tokens_pkey;
+
+ALTER TABLE refresh_tokens
+DROP COLUMN token;
+
+ALTER TABLE refresh_tokens
+ALTER COLUMN token_hash SET NOT NULL;
+
+ALTER TABLE refresh_tokens
+ADD PRIMARY KEY (id),
+ADD CONSTRAINT refresh_tokens_token_hash_key UNIQUE (token_hash);
+
+CREATE INDEX refresh_tokens_user_id_idx ON refresh_tokens (user_id);
+
+-- +goose Down
+-- Hashed tokens can't be turned back into plaintext, so every session is dropped.
+DELETE FROM refresh_tokens;
+
+DROP INDEX refresh_tokens_user_id_idx;
+
+ALTER TABLE refresh_tokens
+DROP CONSTRAINT refresh_tokens_token_hash_key,
+DROP CONSTRAINT refresh_tokens_pkey;
+
+ALTER TABLE refresh_tokens
+ADD COLUMN token TEXT PRIMARY KEY;
+
+ALTER TABLE refresh_tokens
+DROP COLUMN last_used_at,
+DROP COLUMN device_label,
+DROP COLUMN ip_address,
+DROP COLUMN user_agent,
+DROP COLUMN token_hash,
+DROP COLUMN id;
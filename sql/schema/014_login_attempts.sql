@@ -0,0 +1,21 @@
+-- +goose Up
+CREATE TABLE login_attempts (
+  key TEXT PRIMARY KEY,
+  failures INTEGER NOT NULL DEFAULT 0,
+  last_failure_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE audit_events (
+  id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+  user_id uuid REFERENCES users(id) ON DELETE SET NULL,
+  event_type TEXT NOT NULL,
+  ip_address TEXT,
+  detail TEXT,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX audit_events_user_id_idx ON audit_events (user_id, created_at);
+
+-- +goose Down
+DROP TABLE audit_events;
+DROP TABLE login_attempts;
@@ -0,0 +1,13 @@
+-- +goose Up
+CREATE TABLE revoked_tokens (
+  id BIGSERIAL PRIMARY KEY,
+  kind TEXT NOT NULL CHECK (kind IN ('jti', 'sid', 'user')),
+  value TEXT NOT NULL,
+  revoked_at TIMESTAMP NOT NULL,
+  expires_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX revoked_tokens_expires_at_idx ON revoked_tokens (expires_at);
+
+-- +goose Down
+DROP TABLE revoked_tokens;
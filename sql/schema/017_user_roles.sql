@@ -0,0 +1,11 @@
+-- +goose Up
+ALTER TABLE users
+ADD COLUMN role TEXT NOT NULL DEFAULT 'user' CHECK (role IN ('user', 'admin')),
+ADD COLUMN disabled_at TIMESTAMP,
+ADD COLUMN password_reset_required BOOLEAN NOT NULL DEFAULT false;
+
+-- +goose Down
+ALTER TABLE users
+DROP COLUMN role,
+DROP COLUMN disabled_at,
+DROP COLUMN password_reset_required;
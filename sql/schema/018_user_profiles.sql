@@ -0,0 +1,13 @@
+-- +goose Up
+ALTER TABLE users
+ADD COLUMN display_name TEXT,
+ADD COLUMN avatar_url TEXT,
+ADD COLUMN locale TEXT,
+ADD COLUMN timezone TEXT;
+
+-- +goose Down
+ALTER TABLE users
+DROP COLUMN display_name,
+DROP COLUMN avatar_url,
+DROP COLUMN locale,
+DROP COLUMN timezone;
@@ -0,0 +1,13 @@
+-- +goose Up
+CREATE INDEX users_created_at_id_idx ON users (created_at, id);
+
+CREATE INDEX group_members_group_id_created_at_idx ON group_members (group_id, created_at, user_id);
+
+CREATE INDEX group_invites_group_id_created_at_idx ON group_invites (group_id, created_at, id);
+
+-- +goose Down
+DROP INDEX group_invites_group_id_created_at_idx;
+
+DROP INDEX group_members_group_id_created_at_idx;
+
+DROP INDEX users_created_at_id_idx;
@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE mfa_challenges ADD COLUMN expires_in_seconds BIGINT NOT NULL DEFAULT 0;
+
+-- +goose Down
+ALTER TABLE mfa_challenges DROP COLUMN expires_in_seconds;
@@ -0,0 +1,11 @@
+-- +goose Up
+-- clock_timestamp() rather than CURRENT_TIMESTAMP, which is fixed when the
+-- transaction starts.
+ALTER TABLE revoked_tokens ADD COLUMN created_at TIMESTAMP NOT NULL DEFAULT clock_timestamp();
+
+CREATE INDEX revoked_tokens_created_at_idx ON revoked_tokens (created_at);
+
+-- +goose Down
+DROP INDEX revoked_tokens_created_at_idx;
+
+ALTER TABLE revoked_tokens DROP COLUMN created_at;